@@ -0,0 +1,212 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/gogap/errors"
+)
+
+const (
+	_HEAD_COMMIT = "HEAD"
+)
+
+// cmdCheck compare the data of redis with the committed HEAD, it modify
+// neither redis nor the working tree
+func cmdCheck(c *cli.Context) {
+	var err error
+
+	defer func() {
+		if err != nil {
+			exitError(err)
+		}
+	}()
+
+	if !checkIsSyncDir() {
+		err = ERR_THE_CWD_IS_NOT_SYNC_DIR.New()
+		return
+	}
+
+	token := c.String("token")
+	if token == "" {
+		token = getLocalSyncToken()
+	}
+
+	if err = initalConfig(c.String("config")); err != nil {
+		return
+	}
+
+	if err = selectRemote(c.String("remote")); err != nil {
+		return
+	}
+
+	if err = checkRedisHealth(); err != nil {
+		return
+	}
+
+	if count := c.Int("scan-count"); count > 0 {
+		conf.Redis.ScanCount = count
+	}
+
+	if patterns := c.StringSlice("match"); len(patterns) > 0 {
+		conf.Include = patterns
+	}
+
+	if err = checkRedisSyncToken(token); err != nil {
+		return
+	}
+
+	var redisData, commitData map[string][]PushData
+	if commitData, err = getCommitData(_HEAD_COMMIT); err != nil {
+		return
+	}
+
+	if redisData, err = getRedisData(); err != nil {
+		return
+	}
+
+	if drifts := printDriftReport(commitData, redisData); drifts > 0 {
+		err = ERR_DATA_DRIFT_DETECTED.New(errors.Params{"count": drifts, "commit": _HEAD_COMMIT})
+		return
+	}
+}
+
+// checkRedisSyncToken make sure the redis is the one synced with the data
+// dir before reading it, it never write the token
+func checkRedisSyncToken(token string) (err error) {
+	if redisToken, redisTokenExist, e := getRedisSyncToken(); e != nil {
+		err = e
+		return
+	} else if redisTokenExist && redisToken != token {
+		err = ERR_SYNC_TOKEN_NOT_MATCH.New()
+		return
+	}
+
+	return
+}
+
+// printDriftReport print the keys and fields of redis which are different
+// from the commit, grouped by key
+func printDriftReport(commitData, redisData map[string][]PushData) (drifts int) {
+	missing, extra, changed := diffData(commitData, redisData)
+
+	ops := []outputOperation{}
+
+	for _, data := range missing {
+		ops = append(ops, outputOperation{Op: "MISSING", Key: data.Key, Field: data.Field, Type: data.Type, LocalValue: data.Value,
+			line: fmt.Sprintf("[MISSING]\t %s not exist in redis", driftName(data))})
+	}
+
+	for _, data := range changed {
+		redisItem, _ := findDataItem(redisData, data.Key, data.Field)
+		ops = append(ops, outputOperation{Op: "CHANGED", Key: data.Key, Field: data.Field, Type: data.Type, LocalValue: data.Value, RedisValue: redisItem.Value,
+			line: fmt.Sprintf("[CHANGED]\t %s HEAD: '%s', redis: '%s'", driftName(data), data.Value, redisItem.Value)})
+	}
+
+	for _, data := range extra {
+		ops = append(ops, outputOperation{Op: "EXTRA", Key: data.Key, Field: data.Field, Type: data.Type, RedisValue: data.Value,
+			line: fmt.Sprintf("[EXTRA]\t %s not exist in HEAD", driftName(data))})
+	}
+
+	printPlan(ops)
+
+	printSummary(map[string]interface{}{"missing": len(missing), "changed": len(changed), "extra": len(extra)},
+		"missing: %d, changed: %d, extra: %d\n", len(missing), len(changed), len(extra))
+
+	return len(ops)
+}
+
+func driftName(data PushData) string {
+	if data.Field != "" {
+		return fmt.Sprintf("'%s' '%s'", data.Key, data.Field)
+	}
+	return fmt.Sprintf("'%s'", data.Key)
+}
+
+// getCommitData read the data of commit, the files of commit are extracted
+// to a temp dir by git archive, so the working tree and index are untouched
+func getCommitData(commit string) (ret map[string][]PushData, err error) {
+	err = inCommitDir(commit, func() (e error) {
+		ret, e = getLocalData()
+		return
+	})
+	return
+}
+
+// inCommitDir extract the files of commit to a temp dir, and run fn in it,
+// the cwd is restored after fn returned
+func inCommitDir(commit string, fn func() error) (err error) {
+	repo := GitRepo{}
+
+	if e := repo.Archive(commit); e != nil {
+		err = ERR_READ_COMMIT_DATA_FAILED.New(errors.Params{"commit": commit, "err": strings.TrimSpace(string(repo.Output))})
+		return
+	}
+
+	var tmpDir, workDir string
+	if tmpDir, err = ioutil.TempDir("", "redis_sync"); err != nil {
+		err = ERR_READ_COMMIT_DATA_FAILED.New(errors.Params{"commit": commit, "err": err})
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err = extractTar(repo.Output, tmpDir); err != nil {
+		err = ERR_READ_COMMIT_DATA_FAILED.New(errors.Params{"commit": commit, "err": err})
+		return
+	}
+
+	if workDir, err = os.Getwd(); err != nil {
+		err = ERR_GET_CWD_FAILED.New(errors.Params{"err": err})
+		return
+	}
+
+	if err = os.Chdir(tmpDir); err != nil {
+		err = ERR_READ_COMMIT_DATA_FAILED.New(errors.Params{"commit": commit, "err": err})
+		return
+	}
+	defer os.Chdir(workDir)
+
+	return fn()
+}
+
+func extractTar(data []byte, dir string) (err error) {
+	reader := tar.NewReader(bytes.NewReader(data))
+
+	for {
+		var header *tar.Header
+		if header, err = reader.Next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(header.Name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(path, 0755); err != nil {
+				return
+			}
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return
+			}
+
+			var content []byte
+			if content, err = ioutil.ReadAll(reader); err != nil {
+				return
+			}
+
+			if err = ioutil.WriteFile(path, content, 0644); err != nil {
+				return
+			}
+		}
+	}
+}
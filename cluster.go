@@ -0,0 +1,424 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gogap/errors"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	_CLUSTER_SLOTS = 16384
+)
+
+type clusterConfig struct {
+	Addresses []string `json:"addresses"`
+}
+
+type clusterCommand struct {
+	Node string
+	Name string
+	Args []interface{}
+}
+
+// clusterConn is the redigo.Conn of redis cluster, the commands are routed to
+// the master of the slot of their first key, the keyless commands are sent
+// to the first master, MOVED and ASK replies are redirected with the new
+// connections, so the pending replies of the node connections are kept
+type clusterConn struct {
+	options []redigo.DialOption
+
+	slots   [_CLUSTER_SLOTS]string
+	masters []string
+	conns   map[string]redigo.Conn
+
+	// watchConns are the connections of the slots with watched keys, every
+	// slot has its own connection, because EXEC will unwatch all the keys of
+	// the connection
+	watchConns map[int]redigo.Conn
+
+	pending []clusterCommand
+}
+
+func dialRedisCluster(options []redigo.DialOption) (conn redigo.Conn, err error) {
+	cluster := &clusterConn{
+		options:    options,
+		conns:      make(map[string]redigo.Conn),
+		watchConns: make(map[int]redigo.Conn),
+	}
+
+	if err = cluster.loadSlots(); err != nil {
+		return
+	}
+
+	conn = cluster
+	return
+}
+
+// loadSlots read the slot map by CLUSTER SLOTS from the known masters and the
+// seed addresses of config
+func (p *clusterConn) loadSlots() (err error) {
+	var lastErr error
+	for _, address := range append(p.masters, conf.Redis.Cluster.Addresses...) {
+		if lastErr = p.loadSlotsFrom(address); lastErr == nil {
+			return
+		}
+	}
+
+	err = ERR_LOAD_CLUSTER_SLOTS_FAILED.New(errors.Params{"err": lastErr})
+	return
+}
+
+func (p *clusterConn) loadSlotsFrom(address string) (err error) {
+	var conn redigo.Conn
+	if conn, err = dialRedisNode("tcp", address, p.options); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var ranges []interface{}
+	if ranges, err = redigo.Values(conn.Do("CLUSTER", "SLOTS")); err != nil {
+		return
+	}
+
+	seedHost, _, _ := net.SplitHostPort(address)
+
+	slots := [_CLUSTER_SLOTS]string{}
+	masters := []string{}
+	masterExist := map[string]bool{}
+
+	for _, r := range ranges {
+		var values []interface{}
+		if values, err = redigo.Values(r, nil); err != nil {
+			return
+		} else if len(values) < 3 {
+			err = errors.New("unexpected cluster slots reply")
+			return
+		}
+
+		var start, end int
+		var node []interface{}
+		if start, err = redigo.Int(values[0], nil); err != nil {
+			return
+		} else if end, err = redigo.Int(values[1], nil); err != nil {
+			return
+		} else if node, err = redigo.Values(values[2], nil); err != nil {
+			return
+		} else if len(node) < 2 {
+			err = errors.New("unexpected cluster slots reply")
+			return
+		}
+
+		var host string
+		var port int
+		if host, err = redigo.String(node[0], nil); err != nil {
+			return
+		} else if port, err = redigo.Int(node[1], nil); err != nil {
+			return
+		}
+
+		if host == "" {
+			host = seedHost
+		}
+
+		master := net.JoinHostPort(host, strconv.Itoa(port))
+		if !masterExist[master] {
+			masterExist[master] = true
+			masters = append(masters, master)
+		}
+
+		for slot := start; slot <= end && slot < _CLUSTER_SLOTS; slot++ {
+			slots[slot] = master
+		}
+	}
+
+	if len(masters) == 0 {
+		err = errors.New("no slot served by the cluster")
+		return
+	}
+
+	p.slots = slots
+	p.masters = masters
+
+	return
+}
+
+func (p *clusterConn) nodeConn(address string) (conn redigo.Conn, err error) {
+	if conn = p.conns[address]; conn != nil {
+		return
+	}
+
+	if conn, err = dialRedisNode("tcp", address, p.options); err != nil {
+		return
+	}
+
+	p.conns[address] = conn
+	return
+}
+
+// Masters return the connections of all the masters, SCAN should be sent to
+// every one of them
+func (p *clusterConn) Masters() (conns []redigo.Conn, err error) {
+	for _, address := range p.masters {
+		var conn redigo.Conn
+		if conn, err = p.nodeConn(address); err != nil {
+			return
+		}
+		conns = append(conns, conn)
+	}
+	return
+}
+
+func (p *clusterConn) slotNode(slot int) (address string, err error) {
+	if address = p.slots[slot]; address == "" {
+		err = ERR_CLUSTER_SLOT_NOT_COVERED.New(errors.Params{"slot": slot})
+	}
+	return
+}
+
+func (p *clusterConn) commandNode(args []interface{}) (address string, err error) {
+	if len(args) == 0 {
+		return p.masters[0], nil
+	}
+	return p.slotNode(clusterKeySlot(fmt.Sprint(args[0])))
+}
+
+func (p *clusterConn) Close() error {
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+
+	for _, conn := range p.watchConns {
+		conn.Close()
+	}
+
+	return nil
+}
+
+func (p *clusterConn) Err() error {
+	for _, conn := range p.conns {
+		if e := conn.Err(); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (p *clusterConn) Send(cmd string, args ...interface{}) (err error) {
+	var address string
+	if address, err = p.commandNode(args); err != nil {
+		return
+	}
+
+	var conn redigo.Conn
+	if conn, err = p.nodeConn(address); err != nil {
+		return
+	}
+
+	if err = conn.Send(cmd, args...); err != nil {
+		return
+	}
+
+	p.pending = append(p.pending, clusterCommand{Node: address, Name: cmd, Args: args})
+	return
+}
+
+func (p *clusterConn) Flush() (err error) {
+	flushed := map[string]bool{}
+	for _, cmd := range p.pending {
+		if flushed[cmd.Node] {
+			continue
+		}
+
+		if err = p.conns[cmd.Node].Flush(); err != nil {
+			return
+		}
+		flushed[cmd.Node] = true
+	}
+	return
+}
+
+func (p *clusterConn) Receive() (reply interface{}, err error) {
+	if len(p.pending) == 0 {
+		err = errors.New("no pending command of cluster")
+		return
+	}
+
+	cmd := p.pending[0]
+	p.pending = p.pending[1:]
+
+	if reply, err = p.conns[cmd.Node].Receive(); err != nil {
+		return p.redirect(cmd, err)
+	}
+
+	return
+}
+
+// Do receive the pending replies first, as same as redigo does
+func (p *clusterConn) Do(cmd string, args ...interface{}) (reply interface{}, err error) {
+	if len(p.pending) > 0 {
+		if err = p.Flush(); err != nil {
+			return
+		}
+
+		for len(p.pending) > 0 {
+			if _, e := p.Receive(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+
+	if cmd == "" {
+		return
+	}
+
+	var address string
+	if address, err = p.commandNode(args); err != nil {
+		return
+	}
+
+	var conn redigo.Conn
+	if conn, err = p.nodeConn(address); err != nil {
+		return
+	}
+
+	if reply, err = conn.Do(cmd, args...); err != nil {
+		return p.redirect(clusterCommand{Node: address, Name: cmd, Args: args}, err)
+	}
+
+	return
+}
+
+// redirect run the command again on the node of MOVED or ASK reply, with a
+// new connection, the slot map will be reloaded after MOVED
+func (p *clusterConn) redirect(cmd clusterCommand, replyErr error) (reply interface{}, err error) {
+	err = replyErr
+
+	redisErr, ok := replyErr.(redigo.Error)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(string(redisErr))
+	if len(parts) != 3 || (parts[0] != "MOVED" && parts[0] != "ASK") {
+		return
+	}
+
+	if parts[0] == "MOVED" {
+		if e := p.loadSlots(); e != nil {
+			return nil, e
+		}
+	}
+
+	var conn redigo.Conn
+	if conn, err = dialRedisNode("tcp", parts[2], p.options); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if parts[0] == "ASK" {
+		if _, err = conn.Do("ASKING"); err != nil {
+			return
+		}
+	}
+
+	return conn.Do(cmd.Name, cmd.Args...)
+}
+
+// Watch watch the keys on the connections of their slots
+func (p *clusterConn) Watch(keys ...string) (err error) {
+	slotKeys := map[int][]interface{}{}
+	for _, key := range keys {
+		slot := clusterKeySlot(key)
+		slotKeys[slot] = append(slotKeys[slot], key)
+	}
+
+	for slot, args := range slotKeys {
+		conn := p.watchConns[slot]
+		if conn == nil {
+			var address string
+			if address, err = p.slotNode(slot); err != nil {
+				return
+			}
+
+			if conn, err = dialRedisNode("tcp", address, p.options); err != nil {
+				return
+			}
+			p.watchConns[slot] = conn
+		}
+
+		if _, e := conn.Do("WATCH", args...); e != nil {
+			err = redisCommandError("WATCH", e, ERR_WATCH_REDIS_KEYS_FAILED.New(errors.Params{"err": e}))
+			return
+		}
+	}
+
+	return
+}
+
+// Exec apply the commands in one transaction per slot, the transaction of
+// redis cluster could not cross the slots, so the slots committed before the
+// failed one are kept
+func (p *clusterConn) Exec(commands []redisCommand) (err error) {
+	slots := []int{}
+	slotCommands := map[int][]redisCommand{}
+	for _, cmd := range commands {
+		slot := clusterKeySlot(fmt.Sprint(cmd.Args[0]))
+		if _, exist := slotCommands[slot]; !exist {
+			slots = append(slots, slot)
+		}
+		slotCommands[slot] = append(slotCommands[slot], cmd)
+	}
+
+	for i, slot := range slots {
+		conn := p.watchConns[slot]
+		if conn == nil {
+			var address string
+			if address, err = p.slotNode(slot); err != nil {
+				return
+			}
+
+			if conn, err = p.nodeConn(address); err != nil {
+				return
+			}
+		}
+
+		if e := execTransaction(conn, slotCommands[slot]); e != nil {
+			if i == 0 {
+				err = e
+			} else {
+				err = ERR_CLUSTER_PARTIAL_COMMITTED.New(errors.Params{"committed": i, "slot": slot, "err": e})
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// clusterKeySlot is the CRC16 of key mod 16384, only the hash tag between the
+// first '{' and the next '}' is hashed while it is not empty
+func clusterKeySlot(key string) int {
+	if start := strings.Index(key, "{"); start >= 0 {
+		if end := strings.Index(key[start+1:], "}"); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	crc := uint16(0)
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return int(crc) % _CLUSTER_SLOTS
+}
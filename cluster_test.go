@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// clusterKeylessCommands are routed to any node, the others are routed by
+// the slot of their first argument
+var clusterKeylessCommands = map[string]bool{
+	"PING": true, "SCAN": true, "MULTI": true, "EXEC": true, "DISCARD": true,
+	"UNWATCH": true, "ROLE": true, "INFO": true, "DBSIZE": true, "FLUSHDB": true,
+}
+
+// fakeCluster is the redis cluster of fake servers, every node serves its
+// ranges of slots, the keys of the other slots are replied by MOVED, and the
+// migrating keys are replied by ASK
+type fakeCluster struct {
+	sync.Mutex
+
+	nodes []*fakeRedisServer
+	slots [_CLUSTER_SLOTS]*fakeRedisServer
+
+	// migrating are the keys moving to the other node
+	migrating map[string]*fakeRedisServer
+}
+
+// startFakeCluster start one node for every range of slots, the slots not in
+// the ranges are not served
+func startFakeCluster(t *testing.T, ranges ...[2]int) *fakeCluster {
+	cluster := &fakeCluster{migrating: map[string]*fakeRedisServer{}}
+
+	for _, r := range ranges {
+		node := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+		node.store.Lock()
+		node.cluster = cluster
+		node.store.Unlock()
+
+		cluster.nodes = append(cluster.nodes, node)
+		for slot := r[0]; slot <= r[1]; slot++ {
+			cluster.slots[slot] = node
+		}
+	}
+
+	return cluster
+}
+
+func (p *fakeCluster) config() string {
+	return `{"redis": {"cluster": {"addresses": ["` + p.nodes[0].Address() + `"]}}}`
+}
+
+func (p *fakeCluster) owner(key string) *fakeRedisServer {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.slots[clusterKeySlot(key)]
+}
+
+// moveKey move the slot of key to the node, with the keys of the slot
+func (p *fakeCluster) moveKey(key string, node *fakeRedisServer) {
+	from := p.owner(key)
+
+	p.Lock()
+	p.slots[clusterKeySlot(key)] = node
+	p.Unlock()
+
+	setRedisKey(node, key, redisKey(from, key))
+	setRedisKey(from, key, nil)
+}
+
+// migrateKey move the key to the node while its slot is still served by the
+// old node, so the old node replies ASK
+func (p *fakeCluster) migrateKey(key string, node *fakeRedisServer) {
+	from := p.owner(key)
+
+	p.Lock()
+	p.migrating[key] = node
+	p.Unlock()
+
+	setRedisKey(node, key, redisKey(from, key))
+	setRedisKey(from, key, nil)
+}
+
+func (p *fakeCluster) slotsReply() interface{} {
+	p.Lock()
+	defer p.Unlock()
+
+	reply := []interface{}{}
+	for start := 0; start < _CLUSTER_SLOTS; {
+		node, end := p.slots[start], start
+		for end+1 < _CLUSTER_SLOTS && p.slots[end+1] == node {
+			end++
+		}
+
+		if node != nil {
+			host, port, _ := net.SplitHostPort(node.Address())
+			portNum, _ := strconv.Atoi(port)
+			reply = append(reply, []interface{}{int64(start), int64(end), []interface{}{[]byte(host), int64(portNum)}})
+		}
+		start = end + 1
+	}
+
+	return reply
+}
+
+// redirect return the MOVED or ASK reply while the key of command is not
+// served by the node, or nil to run the command
+func (p *fakeCluster) redirect(node *fakeRedisServer, args []string, asking bool) interface{} {
+	if len(args) < 2 || clusterKeylessCommands[strings.ToUpper(args[0])] {
+		return nil
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	slot := clusterKeySlot(args[1])
+	owner := p.slots[slot]
+
+	if target := p.migrating[args[1]]; target != nil {
+		if node == target && asking {
+			return nil
+		} else if node == owner {
+			return redigo.Error(fmt.Sprintf("ASK %d %s", slot, target.Address()))
+		}
+	}
+
+	if owner == nil {
+		return redigo.Error("CLUSTERDOWN Hash slot not served")
+	} else if owner != node {
+		return redigo.Error(fmt.Sprintf("MOVED %d %s", slot, owner.Address()))
+	}
+
+	return nil
+}
+
+func TestClusterPushPull(t *testing.T) {
+	cluster := startFakeCluster(t, [2]int{0, 8191}, [2]int{8192, _CLUSTER_SLOTS - 1})
+	initTestSyncDir(t, cluster.config())
+	writeTestData(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	served := map[*fakeRedisServer]bool{}
+	for _, key := range []string{"k1", "k2", "h", "l", "s", "z"} {
+		owner := cluster.owner(key)
+		if redisKey(owner, key) == nil {
+			t.Errorf("%s is not on the node of slot %d", key, clusterKeySlot(key))
+		}
+		served[owner] = true
+	}
+
+	if len(served) != 2 {
+		t.Errorf("the keys are served by %d nodes, want 2", len(served))
+	}
+
+	setRedisKey(cluster.owner("k1"), "k1", &memoryValue{Type: "string", Value: "changed"})
+	setRedisKey(cluster.owner("s"), "s", &memoryValue{Type: "set", Scores: map[string]float64{"c": 0}})
+	setRedisKey(cluster.owner("n"), "n", &memoryValue{Type: "list", List: []string{"1"}})
+
+	if code := runCommand(t, "pull"); code != _EXIT_OK {
+		t.Fatalf("pull exit with %d", code)
+	}
+
+	// the keys of both nodes are pulled by SCAN on every master
+	assertInSync(t)
+}
+
+func TestClusterRedirect(t *testing.T) {
+	cluster := startFakeCluster(t, [2]int{0, 8191}, [2]int{8192, _CLUSTER_SLOTS - 1})
+	initTestSyncDir(t, cluster.config())
+	writeTestData(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	if e := initalConfig(""); e != nil {
+		t.Fatal(e)
+	}
+
+	conn, e := dialRedis()
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	// the slot map is loaded, h is moved and l is migrating after it
+	other := cluster.nodes[0]
+	if cluster.owner("h") == other || cluster.owner("l") == other {
+		t.Fatal("h and l should be served by the second node")
+	}
+
+	cluster.moveKey("h", other)
+	cluster.migrateKey("l", other)
+
+	pipe := newRedisPipeline(conn, 10)
+
+	snapshot, e := pipe.Snapshot([]string{"k1", "h", "l"}, false)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if !reflect.DeepEqual(snapshot["h"].Fields, map[string]string{"f1": "a", "f2": "b"}) {
+		t.Errorf("snapshot of h = %+v, want the hash of the moved slot", snapshot["h"])
+	}
+	if snapshot["l"].Type != "list" || snapshot["k1"].Value != "v1" {
+		t.Errorf("snapshot of l = %+v, k1 = %+v, want the list asked and k1 not redirected", snapshot["l"], snapshot["k1"])
+	}
+
+	if address := conn.(*clusterConn).slots[clusterKeySlot("h")]; address != other.Address() {
+		t.Errorf("the slot of h = %s, want reloaded to %s", address, other.Address())
+	}
+
+	commands := []redisCommand{
+		{Name: "HSET", Args: []interface{}{"h", "f3", "c"}},
+		{Name: "RPUSH", Args: []interface{}{"l", "z"}},
+	}
+	if e := pipe.Do(commands); e != nil {
+		t.Fatal(e)
+	}
+
+	if value := redisKey(other, "h"); value == nil || value.Fields["f3"] != "c" {
+		t.Errorf("h = %+v, want f3 written to the moved slot", value)
+	}
+	if value := redisKey(other, "l"); value == nil || !reflect.DeepEqual(value.List, []string{"x", "y", "x", "z"}) {
+		t.Errorf("l = %+v, want z written to the migrating key", value)
+	}
+}
+
+func TestClusterAtomic(t *testing.T) {
+	cluster := startFakeCluster(t, [2]int{0, 8191}, [2]int{8192, _CLUSTER_SLOTS - 1})
+	initTestSyncDir(t, cluster.config())
+	writeTestData(t)
+
+	setRedisKey(cluster.owner(_REDIS_SYNC_TOKEN_KEY), _REDIS_SYNC_TOKEN_KEY, &memoryValue{Type: "string", Value: "test-token"})
+	for _, node := range cluster.nodes {
+		node.Commands()
+	}
+
+	if code := runCommand(t, "push", "--atomic", "--watch", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push --atomic exit with %d", code)
+	}
+
+	// every node commits the slots of its keys by their own transactions
+	writes := map[string]bool{"SET": true, "HSET": true, "RPUSH": true, "SADD": true, "ZADD": true, "DEL": true}
+	for i, node := range cluster.nodes {
+		inMulti, execs := false, 0
+		for _, cmd := range node.Commands() {
+			switch {
+			case cmd == "MULTI":
+				inMulti = true
+			case cmd == "EXEC":
+				inMulti = false
+				execs += 1
+			case writes[cmd] && !inMulti:
+				t.Errorf("%s of node %d is out of the transaction", cmd, i)
+			}
+		}
+
+		if execs == 0 {
+			t.Errorf("node %d has no transaction", i)
+		}
+	}
+
+	assertInSync(t)
+}
+
+func TestClusterSlotNotCovered(t *testing.T) {
+	key := ""
+	for i := 0; key == ""; i++ {
+		name := fmt.Sprintf("u%d", i)
+		if slot := clusterKeySlot(name); slot > 0 && slot < _CLUSTER_SLOTS-1 && slot != clusterKeySlot(_REDIS_SYNC_TOKEN_KEY) {
+			key = name
+		}
+	}
+
+	slot := clusterKeySlot(key)
+	cluster := startFakeCluster(t, [2]int{0, slot - 1}, [2]int{slot + 1, _CLUSTER_SLOTS - 1})
+	initTestSyncDir(t, cluster.config())
+
+	writeTestFile(t, "data", `{"`+key+`": "v"}`)
+	commitTestFiles(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_CONNECTIVITY {
+		t.Errorf("push with the slot not covered exit with %d, want %d", code, _EXIT_CONNECTIVITY)
+	}
+
+	if e := initalConfig(""); e != nil {
+		t.Fatal(e)
+	}
+
+	conn, e := dialRedis()
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	if _, e := conn.Do("GET", key); !ERR_CLUSTER_SLOT_NOT_COVERED.IsEqual(e) {
+		t.Errorf("GET %s = %v, want ERR_CLUSTER_SLOT_NOT_COVERED", key, e)
+	}
+}
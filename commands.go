@@ -21,9 +21,44 @@ func commandPush(action cliAction) cli.Command {
 			}, cli.BoolFlag{
 				Name:  "overwrite, o",
 				Usage: "Force overwrite values while value is already exist, default: false",
+			}, cli.BoolFlag{
+				Name:  "no-overwrite",
+				Usage: "Skip the values already exist in redis without asking",
+			}, cli.StringFlag{
+				Name:  "on-conflict",
+				Usage: "The policy of the values already exist in redis: prompt, skip, overwrite or fail, default: prompt",
+			}, cli.BoolFlag{
+				Name:  "yes, y",
+				Usage: "Answer yes to all the questions, it overwrite the conflicts and confirm the prune",
 			}, cli.BoolFlag{
 				Name:  "contine, c",
 				Usage: "Continue on error",
+			}, cli.BoolFlag{
+				Name:  "prune",
+				Usage: "Delete the keys and fields from redis which are not exist in local",
+			}, cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show what would be pushed without changing redis",
+			}, cli.BoolFlag{
+				Name:  "atomic",
+				Usage: "Apply all changes in one MULTI/EXEC transaction",
+			}, cli.BoolFlag{
+				Name:  "watch",
+				Usage: "Watch the pushed keys, abort the atomic push while they were modified by others",
+			}, cli.IntFlag{
+				Name:  "batch-size, b",
+				Value: _DEFAULT_BATCH_SIZE,
+				Usage: "The count of commands sent to redis in one round-trip",
+			}, cli.IntFlag{
+				Name:  "scan-count",
+				Usage: "The COUNT of SCAN, HSCAN, SSCAN and ZSCAN, default: 100",
+			}, cli.StringSliceFlag{
+				Name:  "match, m",
+				Value: &cli.StringSlice{},
+				Usage: "Only sync the keys matched the pattern, it will overwrite the include patterns of config",
+			}, cli.StringFlag{
+				Name:  "remote, r",
+				Usage: "the name of remote in config, default will use the redis of config",
 			}, cli.BoolFlag{
 				Name:  "v",
 				Usage: "Show process details",
@@ -50,6 +85,19 @@ func commandPull(action cliAction) cli.Command {
 			}, cli.BoolFlag{
 				Name:  "overwrite, o",
 				Usage: "Overwrite the value of exist key",
+			}, cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show what would be pulled without changing local data",
+			}, cli.IntFlag{
+				Name:  "scan-count",
+				Usage: "The COUNT of SCAN, HSCAN, SSCAN and ZSCAN, default: 100",
+			}, cli.StringSliceFlag{
+				Name:  "match, m",
+				Value: &cli.StringSlice{},
+				Usage: "Only sync the keys matched the pattern, it will overwrite the include patterns of config",
+			}, cli.StringFlag{
+				Name:  "remote, r",
+				Usage: "the name of remote in config, default will use the redis of config",
 			}, cli.BoolFlag{
 				Name:  "v",
 				Usage: "Show process details",
@@ -89,8 +137,33 @@ func commandCommit(action cliAction) cli.Command {
 func commandDiff(action cliAction) cli.Command {
 	return cli.Command{
 		Name:   "diff",
-		Usage:  "Show changes between commits, commit and working tree, etc",
+		Usage:  "Show changes between commits, commit and working tree, etc, e.g.: diff --redis [commit], diff <commit> <commit>",
 		Action: action,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "config",
+				Usage: "defualt will read config file of redis_conf_sync.conf",
+			}, cli.StringFlag{
+				Name:  "remote, r",
+				Usage: "the name of remote in config, show the changes between the remote and the working tree or commit, without the name it is the redis of config",
+			}, cli.BoolFlag{
+				Name:  "redis",
+				Usage: "Show the changes between the redis of config and the working tree or commit",
+			}, cli.StringFlag{
+				Name:  "token, t",
+				Usage: "sync token",
+			}, cli.IntFlag{
+				Name:  "scan-count",
+				Usage: "The COUNT of SCAN, HSCAN, SSCAN and ZSCAN, default: 100",
+			}, cli.StringSliceFlag{
+				Name:  "match, m",
+				Value: &cli.StringSlice{},
+				Usage: "Only diff the keys matched the pattern, it will overwrite the include patterns of config",
+			}, cli.BoolFlag{
+				Name:  "ttl",
+				Usage: "Show the ttl drift between local and redis",
+			},
+		},
 	}
 }
 
@@ -99,5 +172,105 @@ func commandStatus(action cliAction) cli.Command {
 		Name:   "status",
 		Usage:  "Show the working tree status",
 		Action: action,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "config",
+				Usage: "defualt will read config file of redis_conf_sync.conf",
+			}, cli.StringFlag{
+				Name:  "remote, r",
+				Usage: "the name of remote in config, default will use the redis of config",
+			}, cli.BoolFlag{
+				Name:  "ttl",
+				Usage: "Show the ttl drift between local and redis",
+			},
+		},
+	}
+}
+
+func commandCheck(action cliAction) cli.Command {
+	return cli.Command{
+		Name:   "check",
+		Usage:  "Check the data of redis against the committed HEAD, exit with 8 while they are different",
+		Action: action,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "config",
+				Usage: "defualt will read config file of redis_conf_sync.conf",
+			}, cli.StringFlag{
+				Name:  "token, t",
+				Usage: "sync token",
+			}, cli.IntFlag{
+				Name:  "scan-count",
+				Usage: "The COUNT of SCAN, HSCAN, SSCAN and ZSCAN, default: 100",
+			}, cli.StringSliceFlag{
+				Name:  "match, m",
+				Value: &cli.StringSlice{},
+				Usage: "Only check the keys matched the pattern, it will overwrite the include patterns of config",
+			}, cli.StringFlag{
+				Name:  "remote, r",
+				Usage: "the name of remote in config, default will use the redis of config",
+			},
+		},
+	}
+}
+
+func commandRemote(list, add, remove cliAction) cli.Command {
+	return cli.Command{
+		Name:  "remote",
+		Usage: "Manage the named redis remotes",
+		Subcommands: []cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List the remotes",
+				Action: list,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "config",
+						Usage: "defualt will read config file of redis_conf_sync.conf",
+					},
+				},
+			}, {
+				Name:   "add",
+				Usage:  "Add a remote, e.g.: remote add staging --address 127.0.0.1:6379",
+				Action: add,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "config",
+						Usage: "defualt will read config file of redis_conf_sync.conf",
+					}, cli.StringFlag{
+						Name:  "address",
+						Usage: "redis address",
+					}, cli.IntFlag{
+						Name:  "db",
+						Usage: "redis db",
+					}, cli.StringFlag{
+						Name:  "username",
+						Usage: "redis acl username",
+					}, cli.StringFlag{
+						Name:  "auth",
+						Usage: "redis auth",
+					}, cli.StringFlag{
+						Name:  "auth-env",
+						Usage: "read redis auth from the environment variable",
+					}, cli.StringFlag{
+						Name:  "auth-file",
+						Usage: "read redis auth from the file",
+					}, cli.StringFlag{
+						Name:  "token, t",
+						Usage: "sync token",
+					},
+				},
+			}, {
+				Name:   "remove",
+				Usage:  "Remove a remote, e.g.: remote remove staging",
+				Action: remove,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "config",
+						Usage: "defualt will read config file of redis_conf_sync.conf",
+					},
+				},
+			},
+		},
 	}
 }
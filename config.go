@@ -1,16 +1,44 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"io/ioutil"
+	"strings"
 
 	"github.com/gogap/errors"
 )
 
 type redisConfig struct {
-	Address string `json:"address"`
-	Db      int    `json:"db"`
-	Auth    string `json:"auth"`
+	Network   string `json:"network,omitempty"`
+	Address   string `json:"address"`
+	Db        int    `json:"db"`
+	Username  string `json:"username,omitempty"`
+	Auth      string `json:"auth"`
+	AuthEnv   string `json:"auth_env,omitempty"`
+	AuthFile  string `json:"auth_file,omitempty"`
+	ScanCount int    `json:"scan_count,omitempty"`
+
+	ConnectTimeout int `json:"connect_timeout,omitempty"`
+	ReadTimeout    int `json:"read_timeout,omitempty"`
+	WriteTimeout   int `json:"write_timeout,omitempty"`
+	PoolSize       int `json:"pool_size,omitempty"`
+	MaxRetries     int `json:"max_retries,omitempty"`
+	Protocol       int `json:"protocol,omitempty"`
+
+	TLS      *tlsConfig      `json:"tls,omitempty"`
+	Sentinel *sentinelConfig `json:"sentinel,omitempty"`
+	Cluster  *clusterConfig  `json:"cluster,omitempty"`
+}
+
+type tlsConfig struct {
+	Enable     bool   `json:"enable"`
+	CAFile     string `json:"ca_file,omitempty"`
+	CertFile   string `json:"cert_file,omitempty"`
+	KeyFile    string `json:"key_file,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+	SkipVerify bool   `json:"skip_verify,omitempty"`
 }
 
 type valueType struct {
@@ -22,6 +50,10 @@ type valueType struct {
 type syncConfig struct {
 	Redis      redisConfig `json:"redis"`
 	ValueTypes []valueType `json:"value_types"`
+	Include    []string    `json:"include,omitempty"`
+	Exclude    []string    `json:"exclude,omitempty"`
+
+	Remotes map[string]redisConfig `json:"remotes,omitempty"`
 
 	mapTypes map[string]valueType
 }
@@ -40,11 +72,41 @@ func (p *syncConfig) Load(fileName string) (err error) {
 		return
 	}
 
-	if p.Redis.Address == "" {
-		err = ERR_CONFIG_VALUE_MUST_INPUT.New(errors.Params{"configName": "redis.address"})
+	if err = p.Redis.resolveSecrets("redis"); err != nil {
+		return
+	}
+
+	if err = p.Redis.overrideByEnv(); err != nil {
+		return
+	}
+
+	if err = p.Redis.validate("redis"); err != nil {
 		return
 	}
 
+	for name, remote := range p.Remotes {
+		if err = validateRemoteName(name); err != nil {
+			return
+		}
+
+		if err = remote.resolveSecrets("remotes." + name); err != nil {
+			return
+		}
+
+		if err = remote.validate("remotes." + name); err != nil {
+			return
+		}
+
+		p.Remotes[name] = remote
+	}
+
+	for _, pattern := range append(p.Include, p.Exclude...) {
+		if e := validateKeyPattern(pattern); e != nil {
+			err = ERR_BAD_KEY_PATTERN.New(errors.Params{"pattern": pattern, "err": e})
+			return
+		}
+	}
+
 	if p.ValueTypes != nil &&
 		len(p.ValueTypes) > 0 {
 
@@ -91,6 +153,118 @@ func (p *syncConfig) Load(fileName string) (err error) {
 	return
 }
 
+func (p *redisConfig) validate(configName string) (err error) {
+	if p.Sentinel != nil {
+		if len(p.Sentinel.Addresses) == 0 {
+			err = ERR_CONFIG_VALUE_MUST_INPUT.New(errors.Params{"configName": configName + ".sentinel.addresses"})
+			return
+		}
+
+		if p.Sentinel.MasterName == "" {
+			err = ERR_CONFIG_VALUE_MUST_INPUT.New(errors.Params{"configName": configName + ".sentinel.master_name"})
+			return
+		}
+	}
+
+	if p.Cluster != nil {
+		if p.Sentinel != nil {
+			err = ERR_CONFLICT_CONFIG_VALUE.New(errors.Params{"configName": configName + ".cluster", "conflictName": configName + ".sentinel"})
+			return
+		}
+
+		if p.Db != 0 {
+			err = ERR_CONFLICT_CONFIG_VALUE.New(errors.Params{"configName": configName + ".cluster", "conflictName": configName + ".db"})
+			return
+		}
+
+		if len(p.Cluster.Addresses) == 0 {
+			err = ERR_CONFIG_VALUE_MUST_INPUT.New(errors.Params{"configName": configName + ".cluster.addresses"})
+			return
+		}
+	}
+
+	if p.Sentinel == nil && p.Cluster == nil && p.Address == "" {
+		err = ERR_CONFIG_VALUE_MUST_INPUT.New(errors.Params{"configName": configName + ".address"})
+		return
+	}
+
+	// push holds one connection while reading the ttl by another
+	if p.PoolSize == 1 {
+		err = ERR_CONFIG_VALUE_OUT_OF_RANGE.New(errors.Params{"configName": configName + ".pool_size", "range": "at least 2"})
+		return
+	}
+
+	switch p.Protocol {
+	case 0, _PROTOCOL_RESP2, _PROTOCOL_RESP3:
+	default:
+		err = ERR_CONFIG_VALUE_OUT_OF_RANGE.New(errors.Params{"configName": configName + ".protocol", "range": "2 or 3"})
+		return
+	}
+
+	switch network, _ := p.DialAddress(); network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		err = ERR_UNSUPPORT_REDIS_NETWORK.New(errors.Params{"configName": configName + ".network", "network": network})
+		return
+	}
+
+	_, err = p.TLSConfig()
+	return
+}
+
+// DialAddress return the network and address to dial, the address could be
+// the unix socket of unix:///path/to/redis.sock, or the path while network
+// is unix
+func (p *redisConfig) DialAddress() (network, address string) {
+	if strings.HasPrefix(p.Address, "unix://") {
+		return "unix", strings.TrimPrefix(p.Address, "unix://")
+	}
+
+	if p.Network != "" {
+		return p.Network, p.Address
+	}
+
+	return "tcp", p.Address
+}
+
+// TLSConfig build the tls config of redis connection, it will be nil while
+// tls is not enabled
+func (p *redisConfig) TLSConfig() (tlsConf *tls.Config, err error) {
+	if p.TLS == nil || !p.TLS.Enable {
+		return
+	}
+
+	tlsConf = &tls.Config{
+		ServerName:         p.TLS.ServerName,
+		InsecureSkipVerify: p.TLS.SkipVerify,
+	}
+
+	if p.TLS.CAFile != "" {
+		var caData []byte
+		if caData, err = ioutil.ReadFile(p.TLS.CAFile); err != nil {
+			err = ERR_LOAD_TLS_CONFIG_FAILED.New(errors.Params{"fileName": p.TLS.CAFile, "err": err})
+			return
+		}
+
+		tlsConf.RootCAs = x509.NewCertPool()
+		if !tlsConf.RootCAs.AppendCertsFromPEM(caData) {
+			err = ERR_LOAD_TLS_CONFIG_FAILED.New(errors.Params{"fileName": p.TLS.CAFile, "err": "no certificate found"})
+			return
+		}
+	}
+
+	if p.TLS.CertFile != "" || p.TLS.KeyFile != "" {
+		if cert, e := tls.LoadX509KeyPair(p.TLS.CertFile, p.TLS.KeyFile); e != nil {
+			err = ERR_LOAD_TLS_CONFIG_FAILED.New(errors.Params{"fileName": p.TLS.CertFile, "err": e})
+			return
+		} else {
+			tlsConf.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	return
+}
+
 func (p *syncConfig) Serialize() (str string, err error) {
 	var data []byte
 	if data, err = json.MarshalIndent(p, "", "    "); err != nil {
@@ -117,3 +291,39 @@ func (p *syncConfig) HKeyType(key, field string) (string, bool) {
 
 	return "string", false
 }
+
+// InScope check the key is managed by this repo, the key must match one of
+// the include patterns if they are set, and must not match any of the
+// exclude patterns
+func (p *syncConfig) InScope(key string) bool {
+	if len(p.Include) > 0 {
+		included := false
+		for _, pattern := range p.Include {
+			if matchKeyPattern(pattern, key) {
+				included = true
+				break
+			}
+		}
+
+		if !included {
+			return false
+		}
+	}
+
+	for _, pattern := range p.Exclude {
+		if matchKeyPattern(pattern, key) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ScanPattern is the MATCH pattern of SCAN, it could only be the include
+// pattern while there is only one
+func (p *syncConfig) ScanPattern() string {
+	if len(p.Include) == 1 {
+		return p.Include[0]
+	}
+	return "*"
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"time"
+
+	"github.com/gogap/errors"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	_DEFAULT_CONNECT_TIMEOUT = 5
+	_DEFAULT_READ_TIMEOUT    = 30
+	_DEFAULT_WRITE_TIMEOUT   = 30
+	_DEFAULT_POOL_SIZE       = 3
+	_DEFAULT_MAX_RETRIES     = 3
+
+	_RETRY_BACKOFF     = 100 * time.Millisecond
+	_MAX_RETRY_BACKOFF = 2 * time.Second
+)
+
+var (
+	// redisPool is created at the first dial, after the remote is selected
+	redisPool *redigo.Pool
+)
+
+// dialRedis get the connection of conf.Redis, it is the only entry to connect
+// redis, the connections of single node and sentinel come from the pool, and
+// the cluster connection holds the connections of every node by itself
+func dialRedis() (conn redigo.Conn, err error) {
+	if conf.Redis.Cluster != nil {
+		var options []redigo.DialOption
+		if options, err = redisDialOptions(); err != nil {
+			return
+		}
+		return dialRedisCluster(options)
+	}
+
+	if redisPool == nil {
+		redisPool = newRedisPool()
+	}
+
+	conn = redisPool.Get()
+	if err = conn.Err(); err != nil {
+		conn.Close()
+		conn = nil
+
+		if _, ok := err.(errors.ErrCode); !ok {
+			err = ERR_DAIL_REDIS_FAILED.New(errors.Params{"address": conf.Redis.Address, "err": err})
+		}
+		return
+	}
+
+	return
+}
+
+// checkRedisHealth PING the redis before any work starts, so the bad address,
+// auth or network are reported clearly
+func checkRedisHealth() (err error) {
+	var conn redigo.Conn
+	if conn, err = dialRedis(); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if _, e := conn.Do("PING"); e != nil {
+		err = ERR_DAIL_REDIS_FAILED.New(errors.Params{"address": conf.Redis.Address, "err": e})
+		return
+	}
+
+	return
+}
+
+func newRedisPool() *redigo.Pool {
+	return &redigo.Pool{
+		MaxIdle:   conf.Redis.poolSize(),
+		MaxActive: conf.Redis.poolSize(),
+		Dial: func() (conn redigo.Conn, err error) {
+			var options []redigo.DialOption
+			if options, err = redisDialOptions(); err != nil {
+				return
+			}
+
+			if conf.Redis.Sentinel != nil {
+				return dialSentinelMaster(options)
+			}
+
+			network, address := conf.Redis.DialAddress()
+			return dialRedisNode(network, address, options)
+		},
+		TestOnBorrow: func(conn redigo.Conn, t time.Time) error {
+			// the idle connection may be the old master after failover
+			if conf.Redis.Sentinel != nil && !isRedisMaster(conn) {
+				return errors.New("the connection is not master")
+			}
+			return nil
+		},
+	}
+}
+
+func redisDialOptions() (options []redigo.DialOption, err error) {
+	options = append(redisTimeoutOptions(), redigo.DialDatabase(conf.Redis.Db))
+
+	var tlsConf *tls.Config
+	if tlsConf, err = conf.Redis.TLSConfig(); err != nil {
+		return
+	}
+
+	// the tls and auth of RESP3 are done by the dial func before HELLO
+	if conf.Redis.Protocol == _PROTOCOL_RESP3 {
+		options = append(options, redigo.DialContextFunc(resp3DialFunc(tlsConf)))
+		return
+	}
+
+	options = append(options,
+		redigo.DialUsername(conf.Redis.Username),
+		redigo.DialPassword(conf.Redis.Auth),
+	)
+
+	if tlsConf != nil {
+		options = append(options, redigo.DialUseTLS(true), redigo.DialTLSConfig(tlsConf))
+	}
+
+	return
+}
+
+func redisTimeoutOptions() []redigo.DialOption {
+	return []redigo.DialOption{
+		redigo.DialConnectTimeout(secondsOrDefault(conf.Redis.ConnectTimeout, _DEFAULT_CONNECT_TIMEOUT)),
+		redigo.DialReadTimeout(secondsOrDefault(conf.Redis.ReadTimeout, _DEFAULT_READ_TIMEOUT)),
+		redigo.DialWriteTimeout(secondsOrDefault(conf.Redis.WriteTimeout, _DEFAULT_WRITE_TIMEOUT)),
+	}
+}
+
+// dialRedisNode dial the address, and retry with backoff while the error is
+// transient, the error replies of redis such as the wrong password are not
+// retried. only the dial is retried, the commands failed on the connection
+// are reported by redisCommandError, since the connection may hold WATCH
+func dialRedisNode(network, address string, options []redigo.DialOption) (conn redigo.Conn, err error) {
+	backoff := _RETRY_BACKOFF
+
+	for i := 0; ; i++ {
+		var e error
+		if conn, e = redigo.Dial(network, address, options...); e == nil {
+			return
+		}
+
+		if !isTransientError(e) || i >= conf.Redis.maxRetries() {
+			err = ERR_DAIL_REDIS_FAILED.New(errors.Params{"address": address, "err": e})
+			return
+		}
+
+		time.Sleep(backoff)
+
+		if backoff *= 2; backoff > _MAX_RETRY_BACKOFF {
+			backoff = _MAX_RETRY_BACKOFF
+		}
+	}
+}
+
+func isTransientError(err error) bool {
+	if _, ok := err.(redigo.Error); ok {
+		return false
+	}
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+
+	_, ok := err.(*net.OpError)
+	return ok
+}
+
+// isConnectionError is true while the command failed by the transport, such
+// as the connection reset, closed or timed out, not by the reply of redis
+func isConnectionError(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	_, ok := err.(net.Error)
+	return ok
+}
+
+func secondsOrDefault(seconds, defaultSeconds int) time.Duration {
+	if seconds <= 0 {
+		seconds = defaultSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (p *redisConfig) poolSize() int {
+	if p.PoolSize > 0 {
+		return p.PoolSize
+	}
+	return _DEFAULT_POOL_SIZE
+}
+
+// maxRetries is the retry times of dial, the negative max_retries disable
+// the retry
+func (p *redisConfig) maxRetries() int {
+	if p.MaxRetries < 0 {
+		return 0
+	} else if p.MaxRetries > 0 {
+		return p.MaxRetries
+	}
+	return _DEFAULT_MAX_RETRIES
+}
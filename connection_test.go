@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// testCert is the certificate and key signed by the parent, it is self signed
+// while the parent is nil
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert, isCA bool) *testCert {
+	key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	if isCA {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, e := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cert, e := x509.ParseCertificate(der)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// writeFiles write the certificate and key as pem files in dir
+func (p *testCert) writeFiles(t *testing.T, dir, name string) (certFile, keyFile string) {
+	keyDer, e := x509.MarshalECPrivateKey(p.key)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	writeTestFile(t, certFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.der})))
+	writeTestFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})))
+	return
+}
+
+func (p *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{p.der}, PrivateKey: p.key}
+}
+
+func pingRedis() (err error) {
+	var conn redigo.Conn
+	if conn, err = dialRedis(); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("PING")
+	return
+}
+
+func TestDialRedisTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, "redis_sync ca", nil, true)
+	serverCert := newTestCert(t, "redis", ca, false)
+	clientCert := newTestCert(t, "redis_sync", ca, false)
+	otherCA := newTestCert(t, "other ca", nil, true)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert.tlsCertificate()},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+	otherCAFile, _ := otherCA.writeFiles(t, dir, "other_ca")
+	certFile, keyFile := clientCert.writeFiles(t, dir, "client")
+
+	resetGlobals()
+	defer resetGlobals()
+
+	// RESP3 does the tls handshake by itself before HELLO
+	for _, protocol := range []int{_PROTOCOL_RESP2, _PROTOCOL_RESP3} {
+		resetGlobals()
+		conf.Redis = redisConfig{Address: server.Address(), MaxRetries: -1, Protocol: protocol,
+			TLS: &tlsConfig{Enable: true, CAFile: caFile, CertFile: certFile, KeyFile: keyFile}}
+
+		if tlsConf, e := conf.Redis.TLSConfig(); e != nil || tlsConf.RootCAs == nil || len(tlsConf.Certificates) != 1 {
+			t.Fatalf("TLSConfig() = %v, %v, want the ca and client cert", tlsConf, e)
+		}
+
+		if e := pingRedis(); e != nil {
+			t.Fatalf("ping redis with tls of protocol %d failed: %v", protocol, e)
+		}
+
+		resetGlobals()
+		conf.Redis = redisConfig{Address: server.Address(), MaxRetries: -1, Protocol: protocol,
+			TLS: &tlsConfig{Enable: true, CAFile: otherCAFile, CertFile: certFile, KeyFile: keyFile, SkipVerify: false}}
+
+		if e := pingRedis(); !ERR_DAIL_REDIS_FAILED.IsEqual(e) {
+			t.Errorf("ping redis with the wrong ca of protocol %d = %v, want ERR_DAIL_REDIS_FAILED", protocol, e)
+		}
+	}
+}
+
+func TestDialRedisUnix(t *testing.T) {
+	server := startFakeRedis(t, "unix", filepath.Join(t.TempDir(), "redis.sock"), nil)
+	socket := server.listener.Addr().String()
+
+	resetGlobals()
+	defer resetGlobals()
+
+	for _, redisConf := range []redisConfig{
+		{Address: "unix://" + socket},
+		{Network: "unix", Address: socket},
+	} {
+		resetGlobals()
+		conf.Redis = redisConf
+		conf.Redis.MaxRetries = -1
+
+		if e := conf.Redis.validate("redis_sync.conf"); e != nil {
+			t.Errorf("validate(%+v) = %v, want nil", redisConf, e)
+		}
+
+		if e := pingRedis(); e != nil {
+			t.Errorf("ping redis of %+v failed: %v", redisConf, e)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/codegangsta/cli"
+)
+
+const (
+	_VALUE_ADDED   = "add"
+	_VALUE_REMOVED = "remove"
+	_VALUE_CHANGED = "change"
+)
+
+// valueChange is one change inside the json value, the path is like
+// $.servers[0].host
+type valueChange struct {
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// diffRedis show the changes from redis to the working tree, or to the
+// commit of the first argument, it is what push would change
+func diffRedis(c *cli.Context) (err error) {
+	token := c.String("token")
+	if token == "" {
+		token = getLocalSyncToken()
+	}
+
+	if err = initalConfig(c.String("config")); err != nil {
+		return
+	}
+
+	if err = selectRemote(c.String("remote")); err != nil {
+		return
+	}
+
+	if err = checkRedisHealth(); err != nil {
+		return
+	}
+
+	if count := c.Int("scan-count"); count > 0 {
+		conf.Redis.ScanCount = count
+	}
+
+	if patterns := c.StringSlice("match"); len(patterns) > 0 {
+		conf.Include = patterns
+	}
+
+	if err = checkRedisSyncToken(token); err != nil {
+		return
+	}
+
+	// the local ttl are read with the data, from the commit if it is given
+	var redisData, localData map[string][]PushData
+	var ttls map[string]int64
+	readLocal := func() (e error) {
+		if localData, e = getLocalData(); e != nil {
+			return
+		}
+
+		if c.Bool("ttl") {
+			ttls, e = readTTLFile()
+		}
+		return
+	}
+
+	if commit := c.Args().First(); commit != "" {
+		if err = inCommitDir(commit, readLocal); err != nil {
+			return
+		}
+	} else if err = readLocal(); err != nil {
+		return
+	}
+
+	if redisData, err = getRedisData(); err != nil {
+		return
+	}
+
+	printDataDiff(redisData, localData, false)
+
+	if c.Bool("ttl") {
+		if err = printDataTTLDrifts(localData, ttls); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// diffRemoteArgs rewrite the --remote of diff without a name to --redis, so
+// `diff --remote` diffs with the redis of config, the --remote followed by a
+// name is kept
+func diffRemoteArgs(args []string) []string {
+	ret := append([]string{}, args...)
+
+	isDiff := false
+	for i, arg := range ret {
+		if arg == "diff" {
+			isDiff = true
+		} else if isDiff && (arg == "--remote" || arg == "-remote" || arg == "-r") &&
+			(i+1 == len(ret) || strings.HasPrefix(ret[i+1], "-")) {
+			ret[i] = "--redis"
+		}
+	}
+
+	return ret
+}
+
+// diffCommits show the changes of keys and fields between two commits, the
+// key directories moved without changes are shown as renamed
+func diffCommits(c *cli.Context) (err error) {
+	if err = initalConfig(c.String("config")); err != nil {
+		return
+	}
+
+	if patterns := c.StringSlice("match"); len(patterns) > 0 {
+		conf.Include = patterns
+	}
+
+	var oldData, newData map[string][]PushData
+	if oldData, err = getCommitData(c.Args()[0]); err != nil {
+		return
+	}
+
+	if newData, err = getCommitData(c.Args()[1]); err != nil {
+		return
+	}
+
+	printDataDiff(oldData, newData, true)
+
+	return
+}
+
+// printDataDiff print the changes from oldData to newData by key and field,
+// the key changed type is one change, and the json values are compared by
+// their content
+func printDataDiff(oldData, newData map[string][]PushData, detectRename bool) {
+	keys := sortedDataKeys(newData)
+	for _, key := range sortedDataKeys(oldData) {
+		if _, exist := newData[key]; !exist {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	renamed := map[string]string{}
+	if detectRename {
+		renamed = renamedKeys(oldData, newData)
+	}
+
+	renamedFrom := map[string]string{}
+	for oldKey, newKey := range renamed {
+		renamedFrom[newKey] = oldKey
+	}
+
+	ops := []outputOperation{}
+	added, removed, changed, typeChanged := 0, 0, 0, 0
+
+	for _, key := range keys {
+		if _, exist := renamed[key]; exist {
+			continue
+		} else if oldKey, exist := renamedFrom[key]; exist {
+			ops = append(ops, outputOperation{Op: "RENAMED", Key: key, OldKey: oldKey, Type: newData[key][0].Type,
+				line: fmt.Sprintf("[RENAMED]\t '%s' → '%s'", oldKey, key)})
+			continue
+		}
+
+		oldItems, newItems := oldData[key], newData[key]
+
+		if len(oldItems) > 0 && len(newItems) > 0 && oldItems[0].Type != newItems[0].Type {
+			typeChanged += 1
+			ops = append(ops, outputOperation{Op: "TYPE", Key: key, OldType: oldItems[0].Type, NewType: newItems[0].Type,
+				line: fmt.Sprintf("[TYPE]\t '%s' %s → %s", key, oldItems[0].Type, newItems[0].Type)})
+			continue
+		}
+
+		keyAdded, keyRemoved, keyChanged := diffData(map[string][]PushData{key: newItems}, map[string][]PushData{key: oldItems})
+
+		for _, data := range keyAdded {
+			added += 1
+			ops = append(ops, outputOperation{Op: "ADDED", Key: key, Field: data.Field, Type: data.Type, NewValue: data.Value,
+				line: fmt.Sprintf("[ADDED]\t %s '%s'", driftName(data), data.Value)})
+		}
+
+		for _, data := range keyChanged {
+			oldItem, _ := findDataItem(oldData, key, data.Field)
+			if op, isChanged := changedOperation(oldItem, data); isChanged {
+				changed += 1
+				ops = append(ops, op)
+			}
+		}
+
+		for _, data := range keyRemoved {
+			removed += 1
+			ops = append(ops, outputOperation{Op: "REMOVED", Key: key, Field: data.Field, Type: data.Type, OldValue: data.Value,
+				line: fmt.Sprintf("[REMOVED]\t %s '%s'", driftName(data), data.Value)})
+		}
+	}
+
+	printPlan(ops)
+
+	printSummary(map[string]interface{}{"added": added, "removed": removed, "changed": changed, "type_changed": typeChanged, "renamed": len(renamed)},
+		"added: %d, removed: %d, changed: %d, type changed: %d, renamed: %d\n", added, removed, changed, typeChanged, len(renamed))
+}
+
+// renamedKeys match the keys only in oldData to the keys only in newData,
+// the key of hash, list, set and zset is a directory, while the directory
+// is moved without changing the data files, it is a rename of key
+func renamedKeys(oldData, newData map[string][]PushData) (renamed map[string]string) {
+	renamed = map[string]string{}
+	matched := map[string]bool{}
+
+	for _, oldKey := range sortedDataKeys(oldData) {
+		if _, exist := newData[oldKey]; exist || oldData[oldKey][0].Type == "string" {
+			continue
+		}
+
+		for _, newKey := range sortedDataKeys(newData) {
+			if _, exist := oldData[newKey]; exist || matched[newKey] {
+				continue
+			}
+
+			if isSameItems(oldData[oldKey], newData[newKey]) {
+				renamed[oldKey] = newKey
+				matched[newKey] = true
+				break
+			}
+		}
+	}
+
+	return
+}
+
+func isSameItems(oldItems, newItems []PushData) bool {
+	if len(oldItems) != len(newItems) {
+		return false
+	}
+
+	newItemsMap := map[string][]PushData{"": newItems}
+	for _, item := range oldItems {
+		if newItem, exist := findDataItem(newItemsMap, "", item.Field); !exist || newItem.Type != item.Type || newItem.Value != item.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// changedOperation is the change of value, the json objects and arrays are
+// compared by path, the others are shown as old → new, the json values only
+// different in the order of keys or spaces are not changed
+func changedOperation(oldItem, newItem PushData) (op outputOperation, isChanged bool) {
+	op = outputOperation{Op: "CHANGED", Key: newItem.Key, Field: newItem.Field, Type: newItem.Type, OldValue: oldItem.Value, NewValue: newItem.Value}
+
+	var oldValue, newValue interface{}
+	if !isJSONContainer(oldItem.Value, &oldValue) || !isJSONContainer(newItem.Value, &newValue) {
+		op.line = fmt.Sprintf("[CHANGED]\t %s '%s' → '%s'", driftName(newItem), oldItem.Value, newItem.Value)
+		return op, true
+	}
+
+	if op.Changes = diffJSON("$", oldValue, newValue); len(op.Changes) == 0 {
+		return op, false
+	}
+
+	lines := []string{fmt.Sprintf("[CHANGED]\t %s", driftName(newItem))}
+	for _, change := range op.Changes {
+		switch change.Op {
+		case _VALUE_ADDED:
+			lines = append(lines, fmt.Sprintf("+ %s: %s", change.Path, jsonString(change.New)))
+		case _VALUE_REMOVED:
+			lines = append(lines, fmt.Sprintf("- %s: %s", change.Path, jsonString(change.Old)))
+		case _VALUE_CHANGED:
+			lines = append(lines, fmt.Sprintf("~ %s: %s → %s", change.Path, jsonString(change.Old), jsonString(change.New)))
+		}
+	}
+	op.line = strings.Join(lines, "\n        ")
+
+	return op, true
+}
+
+func isJSONContainer(str string, v *interface{}) bool {
+	if e := json.Unmarshal([]byte(str), v); e != nil {
+		return false
+	}
+
+	switch (*v).(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// diffJSON compare the json values, the objects by keys and the arrays by
+// index
+func diffJSON(path string, oldValue, newValue interface{}) (changes []valueChange) {
+	switch oldV := oldValue.(type) {
+	case map[string]interface{}:
+		if newV, ok := newValue.(map[string]interface{}); ok {
+			names := []string{}
+			for name := range oldV {
+				names = append(names, name)
+			}
+			for name := range newV {
+				if _, exist := oldV[name]; !exist {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				o, oldExist := oldV[name]
+				n, newExist := newV[name]
+				subPath := path + "." + name
+
+				if !oldExist {
+					changes = append(changes, valueChange{Op: _VALUE_ADDED, Path: subPath, New: n})
+				} else if !newExist {
+					changes = append(changes, valueChange{Op: _VALUE_REMOVED, Path: subPath, Old: o})
+				} else {
+					changes = append(changes, diffJSON(subPath, o, n)...)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if newV, ok := newValue.([]interface{}); ok {
+			for i := 0; i < len(oldV) || i < len(newV); i++ {
+				subPath := fmt.Sprintf("%s[%d]", path, i)
+
+				if i >= len(oldV) {
+					changes = append(changes, valueChange{Op: _VALUE_ADDED, Path: subPath, New: newV[i]})
+				} else if i >= len(newV) {
+					changes = append(changes, valueChange{Op: _VALUE_REMOVED, Path: subPath, Old: oldV[i]})
+				} else {
+					changes = append(changes, diffJSON(subPath, oldV[i], newV[i])...)
+				}
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(oldValue, newValue) {
+		changes = append(changes, valueChange{Op: _VALUE_CHANGED, Path: path, Old: oldValue, New: newValue})
+	}
+
+	return
+}
+
+func jsonString(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChangedOperation(t *testing.T) {
+	cases := []struct {
+		oldValue  string
+		newValue  string
+		isChanged bool
+		changes   int
+	}{
+		{`{"b": 2, "a": 1}`, `{"a":1,"b":2}`, false, 0},
+		{`[1, {"a": [true]}]`, `[1,{"a":[true]}]`, false, 0},
+		{`{"a": 1, "b": 2}`, `{"a": 1, "b": 3}`, true, 1},
+		{`{"a": 1}`, `{"b": 1}`, true, 2},
+		{`v1`, `v2`, true, 0},
+	}
+
+	for _, c := range cases {
+		op, isChanged := changedOperation(PushData{Key: "k", Type: "string", Value: c.oldValue}, PushData{Key: "k", Type: "string", Value: c.newValue})
+		if isChanged != c.isChanged || len(op.Changes) != c.changes {
+			t.Errorf("changedOperation(%s, %s) = %v, %d changes, want %v, %d changes", c.oldValue, c.newValue, isChanged, len(op.Changes), c.isChanged, c.changes)
+		}
+	}
+}
+
+func TestPrintDataDiffJSONOrder(t *testing.T) {
+	resetGlobals()
+	defer resetGlobals()
+	outputJSON = true
+
+	oldData := map[string][]PushData{"k": {{Key: "k", Type: "string", Value: `{"b": 2, "a": 1}`}}}
+	newData := map[string][]PushData{"k": {{Key: "k", Type: "string", Value: `{"a":1,"b":2}`}}}
+
+	printDataDiff(oldData, newData, false)
+
+	if len(output.Operations) != 0 || output.Summary["changed"] != 0 {
+		t.Errorf("operations = %v, summary = %v, want nothing changed", output.Operations, output.Summary)
+	}
+}
+
+func TestDiffRedisTTLOfCommit(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+	writeTestData(t)
+
+	writeTestFile(t, _TTL_FILE, `{"k1": 60}`)
+	commitTestFiles(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	// k1 is made persistent in the working tree, but not committed
+	writeTestFile(t, _TTL_FILE, `{}`)
+
+	ttlDrifts := func(args ...string) (drifts []string) {
+		resetGlobals()
+		outputJSON = true
+
+		if code := runCommand(t, append([]string{"diff"}, args...)...); code != _EXIT_OK {
+			t.Fatalf("diff %v exit with %d", args, code)
+		}
+
+		for _, op := range output.Operations {
+			if op.Op == "TTL" {
+				drifts = append(drifts, op.Key)
+			}
+		}
+		return
+	}
+
+	if drifts := ttlDrifts("--ttl", "--remote"); len(drifts) != 1 || drifts[0] != "k1" {
+		t.Errorf("ttl drifts of the working tree = %v, want k1", drifts)
+	}
+
+	if drifts := ttlDrifts("--remote", "--ttl", "HEAD"); len(drifts) != 0 {
+		t.Errorf("ttl drifts of HEAD = %v, want none", drifts)
+	}
+}
+
+func TestDiffRemoteArgs(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"redis_sync", "diff", "--remote"}, "redis_sync diff --redis"},
+		{[]string{"redis_sync", "diff", "-r", "--ttl", "HEAD"}, "redis_sync diff --redis --ttl HEAD"},
+		{[]string{"redis_sync", "diff", "--remote", "production", "HEAD"}, "redis_sync diff --remote production HEAD"},
+		{[]string{"redis_sync", "diff", "--remote=production"}, "redis_sync diff --remote=production"},
+		{[]string{"redis_sync", "push", "--remote"}, "redis_sync push --remote"},
+	}
+
+	for _, c := range cases {
+		if args := strings.Join(diffRemoteArgs(c.args), " "); args != c.want {
+			t.Errorf("diffRemoteArgs(%v) = %s, want %s", c.args, args, c.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gogap/errors"
+)
+
+const (
+	_ENV_ADDRESS  = "REDIS_SYNC_ADDRESS"
+	_ENV_DB       = "REDIS_SYNC_DB"
+	_ENV_USERNAME = "REDIS_SYNC_USERNAME"
+	_ENV_AUTH     = "REDIS_SYNC_AUTH"
+)
+
+var (
+	envVarRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// expandEnv replace the ${ENV_VAR} of value with the environment variable,
+// only the braced form is replaced, so the '$' of passwords is kept
+func expandEnv(configName string, value *string) (err error) {
+	*value = envVarRegexp.ReplaceAllStringFunc(*value, func(match string) string {
+		name := match[2 : len(match)-1]
+
+		envValue, exist := os.LookupEnv(name)
+		if !exist && err == nil {
+			err = ERR_CONFIG_ENV_NOT_SET.New(errors.Params{"name": name, "configName": configName})
+		}
+
+		return envValue
+	})
+
+	return
+}
+
+// resolveSecrets expand the environment variables of the connection config,
+// and read the auth from auth_env or auth_file
+func (p *redisConfig) resolveSecrets(configName string) (err error) {
+	values := map[string]*string{
+		"address":   &p.Address,
+		"username":  &p.Username,
+		"auth":      &p.Auth,
+		"auth_env":  &p.AuthEnv,
+		"auth_file": &p.AuthFile,
+	}
+
+	if p.TLS != nil {
+		values["tls.ca_file"] = &p.TLS.CAFile
+		values["tls.cert_file"] = &p.TLS.CertFile
+		values["tls.key_file"] = &p.TLS.KeyFile
+		values["tls.server_name"] = &p.TLS.ServerName
+	}
+
+	if p.Sentinel != nil {
+		values["sentinel.username"] = &p.Sentinel.Username
+		values["sentinel.auth"] = &p.Sentinel.Auth
+		for i := range p.Sentinel.Addresses {
+			values["sentinel.addresses["+strconv.Itoa(i)+"]"] = &p.Sentinel.Addresses[i]
+		}
+	}
+
+	if p.Cluster != nil {
+		for i := range p.Cluster.Addresses {
+			values["cluster.addresses["+strconv.Itoa(i)+"]"] = &p.Cluster.Addresses[i]
+		}
+	}
+
+	for name, value := range values {
+		if err = expandEnv(configName+"."+name, value); err != nil {
+			return
+		}
+	}
+
+	if p.AuthEnv != "" && p.AuthFile != "" {
+		err = ERR_CONFLICT_CONFIG_VALUE.New(errors.Params{"configName": configName + ".auth_env", "conflictName": configName + ".auth_file"})
+		return
+	}
+
+	if (p.AuthEnv != "" || p.AuthFile != "") && p.Auth != "" {
+		err = ERR_CONFLICT_CONFIG_VALUE.New(errors.Params{"configName": configName + ".auth", "conflictName": configName + ".auth_env or auth_file"})
+		return
+	}
+
+	if p.AuthEnv != "" {
+		auth, exist := os.LookupEnv(p.AuthEnv)
+		if !exist {
+			err = ERR_CONFIG_ENV_NOT_SET.New(errors.Params{"name": p.AuthEnv, "configName": configName + ".auth_env"})
+			return
+		}
+		p.Auth = auth
+	}
+
+	if p.AuthFile != "" {
+		if data, e := ioutil.ReadFile(p.AuthFile); e != nil {
+			err = ERR_READ_AUTH_FILE_FAILED.New(errors.Params{"fileName": p.AuthFile, "err": e})
+			return
+		} else {
+			p.Auth = strings.TrimRight(string(data), "\r\n")
+		}
+	}
+
+	return
+}
+
+// overrideByEnv override the redis config with the REDIS_SYNC_* environment
+// variables, the remotes are not overridden
+func (p *redisConfig) overrideByEnv() (err error) {
+	if address, exist := os.LookupEnv(_ENV_ADDRESS); exist {
+		p.Address = address
+	}
+
+	if strDb, exist := os.LookupEnv(_ENV_DB); exist {
+		if p.Db, err = strconv.Atoi(strDb); err != nil {
+			err = ERR_BAD_ENV_VALUE.New(errors.Params{"name": _ENV_DB, "value": strDb, "err": err})
+			return
+		}
+	}
+
+	if username, exist := os.LookupEnv(_ENV_USERNAME); exist {
+		p.Username = username
+	}
+
+	if auth, exist := os.LookupEnv(_ENV_AUTH); exist {
+		p.Auth = auth
+	}
+
+	return
+}
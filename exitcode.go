@@ -0,0 +1,98 @@
+package main
+
+// the exit codes of redis_sync, the scripts could tell the failure by them,
+// the code of error not in the mapping is _EXIT_ERROR
+const (
+	_EXIT_OK             = 0
+	_EXIT_ERROR          = 1
+	_EXIT_CONFIG         = 2
+	_EXIT_CONNECTIVITY   = 3
+	_EXIT_TOKEN          = 4
+	_EXIT_DIRTY_TREE     = 5
+	_EXIT_DATA_INVALID   = 6
+	_EXIT_CONFLICT       = 7
+	_EXIT_DRIFT_DETECTED = 8
+)
+
+// exitCodes map the errors to exit codes by class
+var exitCodes = []struct {
+	code   int
+	errors []*errTemplate
+}{
+	{_EXIT_CONFIG, []*errTemplate{
+		ERR_LOAD_CONFIG_FAILED,
+		ERR_PARSE_CONFIG_FAILED,
+		ERR_CONFIG_VALUE_MUST_INPUT,
+		ERR_THE_CWD_IS_NOT_SYNC_DIR,
+		ERR_BAD_KEY_PATTERN,
+		ERR_REMOTE_NOT_EXIST,
+		ERR_REMOTE_ALREADY_EXIST,
+		ERR_BAD_REMOTE_NAME,
+		ERR_LOAD_TLS_CONFIG_FAILED,
+		ERR_CONFLICT_CONFIG_VALUE,
+		ERR_UNSUPPORT_REDIS_NETWORK,
+		ERR_CONFIG_ENV_NOT_SET,
+		ERR_READ_AUTH_FILE_FAILED,
+		ERR_BAD_ENV_VALUE,
+		ERR_CONFIG_VALUE_OUT_OF_RANGE,
+		ERR_BAD_FLAG_VALUE,
+		ERR_UNSUPPORT_TYPE_MAPPING,
+		ERR_KEY_TYPES_MAP_ALREADY_EXIST,
+	}},
+	{_EXIT_CONNECTIVITY, []*errTemplate{
+		ERR_DAIL_REDIS_FAILED,
+		ERR_REDIS_ACL_NO_PERMISSION,
+		ERR_RESOLVE_SENTINEL_MASTER_FAILED,
+		ERR_SENTINEL_MASTER_NOT_READY,
+		ERR_LOAD_CLUSTER_SLOTS_FAILED,
+		ERR_CLUSTER_SLOT_NOT_COVERED,
+		ERR_REDIS_CONNECTION_LOST,
+	}},
+	{_EXIT_TOKEN, []*errTemplate{
+		ERR_WRITE_SYNC_TOKEN_FAILED,
+		ERR_GET_REDIS_SYNC_TOKEN_FAILED,
+		ERR_REDIS_ALREADY_HAVE_TOKEN,
+		ERR_SYNC_TOKEN_TO_REDIS_FAILED,
+		ERR_SYNC_TOKEN_NOT_MATCH,
+	}},
+	{_EXIT_DIRTY_TREE, []*errTemplate{
+		ERR_COMMIT_CURRENT_WORKDIR_NOT_CLEAN,
+	}},
+	{_EXIT_DATA_INVALID, []*errTemplate{
+		ERR_REDIS_KEY_IS_EMPTY,
+		ERR_READ_DATAFILE_ERROR,
+		ERR_PARSE_DATAFILE_ERROR,
+		ERR_THE_DATA_KEY_IS_EMPTY,
+		ERR_DATAFILE_COULD_NOT_BE_A_DIR,
+		ERR_REDIS_KEY_TYPE_NOT_MATCH,
+		ERR_REDIS_HKEY_TYPE_NOT_MATCH,
+		ERR_COULD_NOT_CONV_VAL_TO_STRING,
+		ERR_COULD_NOT_CONV_VAL_TO_NUMBER,
+		ERR_COULD_NOT_CONV_VAL_TO_MAP,
+		ERR_COULD_NOT_CONV_VAL_TO_ARRAY,
+		ERR_COULD_NOT_CONV_VAL_TO_BOOL,
+		ERR_KEY_VAL_TYPE_NOT_MATCH_TO_CONF,
+		ERR_HKEY_VAL_TYPE_NOT_MATCH_TO_CONF,
+	}},
+	{_EXIT_CONFLICT, []*errTemplate{
+		ERR_TRANSACTION_ABORTED_BY_WATCH,
+		ERR_PUSH_CONFLICT,
+		ERR_CONFIRM_WITHOUT_TERMINAL,
+	}},
+	{_EXIT_DRIFT_DETECTED, []*errTemplate{
+		ERR_DATA_DRIFT_DETECTED,
+	}},
+}
+
+// exitCode is the exit code of err by its class
+func exitCode(err error) int {
+	for _, class := range exitCodes {
+		for _, errTmpl := range class.errors {
+			if errTmpl.IsEqual(err) {
+				return class.code
+			}
+		}
+	}
+
+	return _EXIT_ERROR
+}
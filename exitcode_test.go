@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/gogap/errors"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func TestExitCode(t *testing.T) {
+	// the server closes every connection, the commands fail by the transport
+	listener, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, e := listener.Accept()
+			if e != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	dial := func() redigo.Conn {
+		conn, e := redigo.Dial("tcp", listener.Addr().String())
+		if e != nil {
+			t.Fatal(e)
+		}
+		return conn
+	}
+
+	closedConn := dial()
+	defer closedConn.Close()
+	_, existsErr := closedConn.Do("EXISTS", _REDIS_SYNC_TOKEN_KEY)
+
+	pipeConn := dial()
+	defer pipeConn.Close()
+	_, pipeErr := newRedisPipeline(pipeConn, 10).Run([]redisCommand{{Name: "TYPE", Args: []interface{}{"k1"}}})
+
+	scanConn := dial()
+	defer scanConn.Close()
+	scanErr := scanKeys(scanConn, "*", &[]string{}, map[string]bool{})
+
+	cases := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"other error", errors.New("unknown"), _EXIT_ERROR},
+		{"command replied error", ERR_EXEC_REDIS_COMMAND_FAILED.New(errors.Params{"cmd": "TYPE", "args": nil, "err": "ERR"}), _EXIT_ERROR},
+		{"bad config", ERR_PARSE_CONFIG_FAILED.New(errors.Params{"fileName": "redis_sync.conf", "err": "bad json"}), _EXIT_CONFIG},
+		{"dial failed", ERR_DAIL_REDIS_FAILED.New(errors.Params{"address": "127.0.0.1:6379", "err": "refused"}), _EXIT_CONNECTIVITY},
+		{"token connection lost", redisCommandError("EXISTS", existsErr, ERR_GET_REDIS_SYNC_TOKEN_FAILED.New()), _EXIT_CONNECTIVITY},
+		{"token connection eof", redisCommandError("GET", io.EOF, ERR_GET_REDIS_SYNC_TOKEN_FAILED.New()), _EXIT_CONNECTIVITY},
+		{"token acl", redisCommandError("SET", redigo.Error("NOPERM no permission"), ERR_SYNC_TOKEN_TO_REDIS_FAILED.New(errors.Params{"err": "NOPERM"})), _EXIT_CONNECTIVITY},
+		{"token wrong type", redisCommandError("GET", redigo.Error("WRONGTYPE"), ERR_GET_REDIS_SYNC_TOKEN_FAILED.New()), _EXIT_TOKEN},
+		{"token not match", ERR_SYNC_TOKEN_NOT_MATCH.New(), _EXIT_TOKEN},
+		{"pipeline connection lost", pipeErr, _EXIT_CONNECTIVITY},
+		{"scan connection lost", scanErr, _EXIT_CONNECTIVITY},
+		{"dirty tree", ERR_COMMIT_CURRENT_WORKDIR_NOT_CLEAN.New(), _EXIT_DIRTY_TREE},
+		{"type not match", ERR_REDIS_KEY_TYPE_NOT_MATCH.New(errors.Params{"key": "k", "originType": "hash", "exceptType": "string"}), _EXIT_DATA_INVALID},
+		{"conflict", ERR_PUSH_CONFLICT.New(errors.Params{"key": "k", "conflict": "changed"}), _EXIT_CONFLICT},
+		{"drift", ERR_DATA_DRIFT_DETECTED.New(errors.Params{"count": 1, "commit": "HEAD"}), _EXIT_DRIFT_DETECTED},
+	}
+
+	for _, c := range cases {
+		if code := exitCode(c.err); code != c.code {
+			t.Errorf("exitCode() of %s = %d, want %d, err: %v", c.name, code, c.code, c.err)
+		}
+	}
+}
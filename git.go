@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"os/exec"
 	"strings"
 )
@@ -23,6 +22,18 @@ func (p *GitRepo) Diff() error {
 	return p.run("diff")
 }
 
+// Archive write the files of commit to Output as tar, the stderr is kept
+// out of the tar, and it is the Output if archive failed
+func (p *GitRepo) Archive(commit string) error {
+	cmd := exec.Command("git", "archive", "--format=tar", commit)
+	if p.Output, p.LastError = cmd.Output(); p.LastError != nil {
+		if exitErr, ok := p.LastError.(*exec.ExitError); ok {
+			p.Output = exitErr.Stderr
+		}
+	}
+	return p.LastError
+}
+
 func (p *GitRepo) Add(files ...string) error {
 	return p.run("add", files...)
 }
@@ -77,8 +88,11 @@ func (p *GitRepo) addStdout(subcmd string, arg ...string) error {
 		return p.LastError
 	}
 
-	n := bytes.Index(p.Output, nil)
-	files := strings.Split(string(p.Output[:n]), "\n")
+	files := strings.Fields(string(p.Output))
+	if len(files) == 0 {
+		return nil
+	}
+
 	p.Add(files...)
 	return p.LastError
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"github.com/gogap/errors"
+)
+
+// matchKeyPattern match the key by the glob of redis SCAN MATCH, it is the
+// same as stringmatchlen of redis, so the keys scanned from redis and read
+// from local are filtered by the same rule, unlike path.Match, the * and ?
+// match '/' too
+func matchKeyPattern(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+
+			if len(pattern) == 1 {
+				return true
+			}
+
+			for i := 0; i <= len(key); i++ {
+				if matchKeyPattern(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			key = key[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+
+			i := 1
+			not := i < len(pattern) && pattern[i] == '^'
+			if not {
+				i++
+			}
+
+			matched := false
+			for ; i < len(pattern) && pattern[i] != ']'; i++ {
+				if pattern[i] == '\\' && i+1 < len(pattern) {
+					i++
+					if pattern[i] == key[0] {
+						matched = true
+					}
+				} else if i+2 < len(pattern) && pattern[i+1] == '-' {
+					start, end := pattern[i], pattern[i+2]
+					if start > end {
+						start, end = end, start
+					}
+					if key[0] >= start && key[0] <= end {
+						matched = true
+					}
+					i += 2
+				} else if pattern[i] == key[0] {
+					matched = true
+				}
+			}
+
+			if i >= len(pattern) {
+				// the bracket is not closed, it ends with the pattern
+				i = len(pattern) - 1
+			}
+
+			if not {
+				matched = !matched
+			}
+
+			if !matched {
+				return false
+			}
+
+			pattern = pattern[i:]
+			key = key[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+			key = key[1:]
+		}
+
+		pattern = pattern[1:]
+	}
+
+	return len(key) == 0
+}
+
+// validateKeyPattern reject the pattern which redis could match but is
+// likely a mistake, the bracket not closed and the trailing backslash
+func validateKeyPattern(pattern string) (err error) {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i++; i >= len(pattern) {
+				return errors.New("the pattern ends with backslash")
+			}
+		case '[':
+			for i++; i < len(pattern) && pattern[i] != ']'; i++ {
+				if pattern[i] == '\\' {
+					i++
+				}
+			}
+
+			if i >= len(pattern) {
+				return errors.New("the bracket is not closed")
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMatchKeyPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		key     string
+		matched bool
+	}{
+		{"*", "", true},
+		{"config:*", "config:a", true},
+		{"config:*", "config:a/b", true},
+		{"config:*", "other:a", false},
+		{"config:?", "config:/", true},
+		{"config:?", "config:ab", false},
+		{"*:tmp:*", "config:tmp:a/b", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"h[\\]]llo", "h]llo", true},
+		{"a**b", "a/x/b", true},
+	}
+
+	for _, c := range cases {
+		if matched := matchKeyPattern(c.pattern, c.key); matched != c.matched {
+			t.Errorf("matchKeyPattern(%q, %q) = %v, want %v", c.pattern, c.key, matched, c.matched)
+		}
+	}
+}
+
+func TestValidateKeyPattern(t *testing.T) {
+	for _, pattern := range []string{"config:*", "h[ae]llo", "h\\[llo", "h[\\]]llo"} {
+		if err := validateKeyPattern(pattern); err != nil {
+			t.Errorf("validateKeyPattern(%q) = %v, want nil", pattern, err)
+		}
+	}
+
+	for _, pattern := range []string{"config:[a", "config:\\", "h[\\]llo"} {
+		if err := validateKeyPattern(pattern); err == nil {
+			t.Errorf("validateKeyPattern(%q) = nil, want error", pattern)
+		}
+	}
+}
+
+func TestInScope(t *testing.T) {
+	conf := syncConfig{Include: []string{"config:*"}, Exclude: []string{"config:tmp:*"}}
+
+	for key, inScope := range map[string]bool{
+		"config:a":       true,
+		"config:a/b":     true,
+		"config:tmp:a/b": false,
+		"other":          false,
+	} {
+		if conf.InScope(key) != inScope {
+			t.Errorf("InScope(%q) = %v, want %v", key, !inScope, inScope)
+		}
+	}
+}
@@ -1,19 +1,20 @@
 package main
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/codegangsta/cli"
 	"github.com/gogap/errors"
-	"github.com/hoisie/redis"
+	redigo "github.com/gomodule/redigo/redis"
 	"github.com/nu7hatch/gouuid"
 )
 
@@ -21,6 +22,7 @@ type PushData struct {
 	Key   string
 	Field string
 	Value string
+	Type  string
 }
 
 const (
@@ -36,6 +38,16 @@ var (
 )
 
 func main() {
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "output",
+			Value: _OUTPUT_TEXT,
+			Usage: "The output format of commands: text or json",
+		},
+	}
+
+	app.Before = setOutput
+
 	app.Commands = []cli.Command{
 		commandPush(cmdPush),
 		commandPull(cmdPull),
@@ -43,9 +55,13 @@ func main() {
 		commandInit(cmdInit),
 		commandStatus(cmdStatus),
 		commandDiff(cmdDiff),
+		commandCheck(cmdCheck),
+		commandRemote(cmdRemoteList, cmdRemoteAdd, cmdRemoteRemove),
 	}
 
-	app.Run(os.Args)
+	app.Run(diffRemoteArgs(os.Args))
+
+	flushOutput()
 }
 
 func cmdStatus(c *cli.Context) {
@@ -68,7 +84,25 @@ func cmdStatus(c *cli.Context) {
 		err = ERR_GET_REPO_STATUS_FAILED.New(errors.Params{"err": e})
 		return
 	} else {
-		fmt.Println(string(repo.Output))
+		printGitOutput(repo.Output)
+	}
+
+	if c.Bool("ttl") {
+		if err = initalConfig(c.String("config")); err != nil {
+			return
+		}
+
+		if err = selectRemote(c.String("remote")); err != nil {
+			return
+		}
+
+		if err = checkRedisHealth(); err != nil {
+			return
+		}
+
+		if err = printTTLDrifts(); err != nil {
+			return
+		}
 	}
 }
 
@@ -86,13 +120,39 @@ func cmdDiff(c *cli.Context) {
 		return
 	}
 
+	if c.Bool("redis") || c.String("remote") != "" {
+		err = diffRedis(c)
+		return
+	} else if len(c.Args()) == 2 {
+		err = diffCommits(c)
+		return
+	}
+
 	repo := GitRepo{}
 
 	if e := repo.Diff(); e != nil {
 		err = ERR_GET_REPO_DIFF_FAILED.New(errors.Params{"err": e})
 		return
 	} else {
-		fmt.Println(string(repo.Output))
+		printGitOutput(repo.Output)
+	}
+
+	if c.Bool("ttl") {
+		if err = initalConfig(c.String("config")); err != nil {
+			return
+		}
+
+		if err = selectRemote(c.String("remote")); err != nil {
+			return
+		}
+
+		if err = checkRedisHealth(); err != nil {
+			return
+		}
+
+		if err = printTTLDrifts(); err != nil {
+			return
+		}
 	}
 }
 
@@ -118,8 +178,33 @@ func cmdPush(c *cli.Context) {
 		return
 	}
 
+	if err = selectRemote(c.String("remote")); err != nil {
+		return
+	}
+
+	if err = checkRedisHealth(); err != nil {
+		return
+	}
+
+	if count := c.Int("scan-count"); count > 0 {
+		conf.Redis.ScanCount = count
+	}
+
+	if patterns := c.StringSlice("match"); len(patterns) > 0 {
+		conf.Include = patterns
+	}
+
 	errorContinue := c.Bool("contine")
-	overWrite := c.Bool("overwrite")
+	prune := c.Bool("prune")
+	dryRun := c.Bool("dry-run")
+	watch := c.Bool("watch")
+	atomic := c.Bool("atomic") || watch
+	batchSize := c.Int("batch-size")
+
+	onConflict := ""
+	if onConflict, err = conflictPolicy(c); err != nil {
+		return
+	}
 
 	redisToken := ""
 	redisTokenExist := false
@@ -138,8 +223,10 @@ func cmdPush(c *cli.Context) {
 			err = ERR_SYNC_TOKEN_NOT_MATCH.New()
 			return
 		}
-	} else if err = pushSyncToken(token); err != nil {
-		return
+	} else if !dryRun {
+		if err = pushSyncToken(token); err != nil {
+			return
+		}
 	}
 
 	workDir := ""
@@ -159,15 +246,17 @@ func cmdPush(c *cli.Context) {
 	pushCache := []PushData{}
 
 	fnWalk := func(path string, info os.FileInfo, e error) (err error) {
-		if info.Name() != "data" {
-			return
-		}
-
 		if info.IsDir() &&
 			strings.HasPrefix(info.Name(), ".") {
 			return filepath.SkipDir
 		}
 
+		switch info.Name() {
+		case "data", "list", "set", "zset":
+		default:
+			return
+		}
+
 		datafile, _ := filepath.Rel(workDir, path)
 
 		if datafile == "." {
@@ -176,12 +265,31 @@ func cmdPush(c *cli.Context) {
 
 		datafileDir := filepath.Dir(datafile)
 
+		if datafileDir != "." && !conf.InScope(datafileDir) {
+			return
+		}
+
 		var data []byte
 		if data, err = ioutil.ReadFile(datafile); err != nil {
 			err = ERR_READ_DATAFILE_ERROR.New(errors.Params{"fileName": datafile, "err": err})
 			return
 		}
 
+		if info.Name() != "data" {
+			//RPUSH, SADD, ZADD
+			if datafileDir == "." {
+				return
+			}
+
+			var pData PushData
+			if pData, err = parseCollectionData(datafileDir, datafile, info.Name(), data); err != nil {
+				return
+			}
+
+			pushCache = append(pushCache, pData)
+			return
+		}
+
 		dataKV := map[string]interface{}{}
 
 		if e := json.Unmarshal(data, &dataKV); e != nil {
@@ -192,6 +300,9 @@ func cmdPush(c *cli.Context) {
 		if datafileDir == "." {
 			//SET
 			for k, v := range dataKV {
+				if !conf.InScope(k) {
+					continue
+				}
 
 				dockeyValType, keyValTypeExist := conf.KeyType(k)
 				if keyValTypeExist {
@@ -212,7 +323,7 @@ func cmdPush(c *cli.Context) {
 					err = ERR_COULD_NOT_CONV_VAL_TO_STRING.New(errors.Params{"key": k, "err": e})
 					return
 				} else {
-					pushCache = append(pushCache, PushData{Key: k, Field: "", Value: strV})
+					pushCache = append(pushCache, PushData{Key: k, Field: "", Value: strV, Type: "string"})
 				}
 
 			}
@@ -240,7 +351,7 @@ func cmdPush(c *cli.Context) {
 					err = ERR_COULD_NOT_CONV_VAL_TO_STRING.New(errors.Params{"key": k, "err": e})
 					return
 				} else {
-					pushCache = append(pushCache, PushData{Key: datafileDir, Field: k, Value: strV})
+					pushCache = append(pushCache, PushData{Key: datafileDir, Field: k, Value: strV, Type: "hash"})
 				}
 			}
 		}
@@ -255,133 +366,252 @@ func cmdPush(c *cli.Context) {
 	ignore := 0
 	pushed := 0
 
-	client := redis.Client{
-		Addr:        conf.Redis.Address,
-		Db:          conf.Redis.Db,
-		Password:    conf.Redis.Auth,
-		MaxPoolSize: 3,
-	}
-
-	consoleReader := bufio.NewReader(os.Stdin)
+	pushedKeys := []string{}
+	keyPushed := map[string]bool{}
 	for _, data := range pushCache {
-		exceptType := "string"
-		if data.Field != "" {
-			exceptType = "hash"
+		if !keyPushed[data.Key] {
+			keyPushed[data.Key] = true
+			pushedKeys = append(pushedKeys, data.Key)
 		}
+	}
+
+	var conn redigo.Conn
+	if conn, err = dialRedis(); err != nil {
+		return
+	}
+	defer conn.Close()
 
-		keyTypeMatchd := false
-		actualKeyType := "none"
+	pipe := newRedisPipeline(conn, batchSize)
 
-		if keyType, e := client.Type(data.Key); e != nil {
-			err = ERR_GET_REDIS_KEY_TYPE_FAILED.New(errors.Params{"key": data.Key, "err": e})
+	if watch && !dryRun {
+		if err = pipe.Watch(pushedKeys...); err != nil {
 			return
-		} else {
-			actualKeyType = keyType
 		}
+	}
 
-		keyTypeMatchd = exceptType == actualKeyType
+	var snapshot map[string]redisValue
+	if snapshot, err = pipe.Snapshot(pushedKeys, errorContinue); err != nil {
+		return
+	}
 
-		if !keyTypeMatchd && !overWrite && actualKeyType != "none" {
-			fmt.Printf("The key: '%s' already exist, but the type is not '%s', do you want overwrite [y/N]: ", data.Key, exceptType)
-			if line, e := consoleReader.ReadByte(); e != nil {
-				err = ERR_READ_USER_INPUT_ERROR.New()
+	commands := []redisCommand{}
+
+	// the plan of dry run is built by the same commands, the questions are
+	// answered yes unless the conflict policy says no
+	planOps := []outputOperation{}
+	declined := map[string]bool{}
+	prompt := newPrompter(c.Bool("yes") || dryRun)
+	for _, data := range pushCache {
+		exceptType := data.Type
+		origin := snapshot[data.Key]
+		actualKeyType := origin.Type
+
+		keyTypeMatchd := exceptType == actualKeyType
+
+		if !keyTypeMatchd && actualKeyType != "none" {
+			question := fmt.Sprintf("The key: '%s' already exist, but the type is not '%s', do you want overwrite", data.Key, exceptType)
+			if overwrite, e := prompt.Resolve(onConflict, data.Key, question); e != nil {
+				err = e
 				return
-			} else if line == 'n' || line == 'N' {
+			} else if !overwrite {
+				declined[data.Key] = true
 				continue
-			} else if line == 'y' || line == 'Y' {
-				if _, e := client.Del(data.Key); e != nil {
-					err = ERR_DELETE_REDIS_KEY_FAILED.New(errors.Params{"key": data.Key, "err": e})
-					return
+			}
+
+			cmd := redisCommand{Name: "DEL", Args: []interface{}{data.Key}}
+			commands = append(commands, cmd)
+			planOps = append(planOps, commandOperation(cmd, actualKeyType))
+		}
+
+		if keyTypeMatchd && !origin.Unknown {
+			originV, exist := origin.Value, true
+			if exceptType == "hash" {
+				originV, exist = origin.Fields[data.Field]
+			}
+
+			if exist && originV == data.Value {
+				op := outputOperation{Op: "IGNORE", Key: data.Key, Field: data.Field, Type: data.Type, Value: data.Value}
+				if exceptType == "hash" {
+					op.line = fmt.Sprintf("[IGNORE] key: '%s', field: '%s', already have value of '%s'", data.Key, data.Field, data.Value)
+				} else {
+					op.line = fmt.Sprintf("[IGNORE] key: '%s' already have value of '%s'", data.Key, data.Value)
 				}
-			} else {
+				if !dryRun {
+					printOperation(viewDetails, op)
+				}
+				ignore += 1
 				continue
+			} else if exist {
+				question := ""
+				if exceptType == "hash" {
+					question = fmt.Sprintf("The key: '%s', field: '%s', already exist, and current value is '%s', do you want overwrite it to '%s'", data.Key, data.Field, originV, data.Value)
+				} else {
+					question = fmt.Sprintf("The key: '%s' already exist, and current value is '%s', do you want overwrite it to '%s'", data.Key, originV, data.Value)
+				}
+
+				if overwrite, e := prompt.Resolve(onConflict, data.Key, question); e != nil {
+					err = e
+					return
+				} else if !overwrite {
+					declined[data.Key] = true
+					continue
+				}
 			}
 		}
 
+		originValue := ""
 		if keyTypeMatchd {
-			if exceptType == "string" {
-				if originV, e := client.Get(data.Key); e != nil {
-					if !errorContinue {
-						err = ERR_GET_REDIS_VALUE_ERROR.New(errors.Params{"key": data.Key, "err": e})
-						return
-					}
-				} else if string(originV) == data.Value {
-					if viewDetails {
-						fmt.Printf("[IGNORE] key: '%s' already have value of '%s'\n", data.Key, data.Value)
-					}
-					ignore += 1
-					continue
-				} else if !overWrite {
-					fmt.Printf("The key: '%s' already exist, and current value is '%s', do you want overwrite it to '%s' [y/N]: ", data.Key, string(originV), data.Value)
-					if line, e := consoleReader.ReadByte(); e != nil {
-						err = ERR_READ_USER_INPUT_ERROR.New()
-						return
-					} else if line == 'n' || line == 'N' {
-						continue
-					} else if line == 'y' || line == 'Y' {
+			originValue = origin.Value
+		}
 
-					} else {
-						continue
-					}
-				}
-			} else {
-				if exist, e := client.Hexists(data.Key, data.Field); e != nil {
-					if !errorContinue {
-						err = ERR_HGET_KEY_STATUS_ERROR.New(errors.Params{"key": data.Key, "field": data.Field, "err": e})
-						return
-					}
-				} else if exist {
-					if originV, e := client.Hget(data.Key, data.Field); e != nil {
-						if !errorContinue {
-							err = ERR_HGET_REDIS_VALUE_ERROR.New(errors.Params{"key": data.Key, "field": data.Field, "err": e})
-							return
-						}
-					} else if string(originV) == data.Value {
-						if viewDetails {
-							fmt.Printf("[IGNORE] key: '%s', field: '%s', already have value of '%s'\n", data.Key, data.Field, data.Value)
-						}
-						ignore += 1
-						continue
-					} else if !overWrite {
-						fmt.Printf("The key: '%s', field: '%s', already exist, and current value is '%s', do you want overwrite it to '%s' [y/N]: ", data.Key, data.Field, string(originV), data.Value)
-						if line, e := consoleReader.ReadByte(); e != nil {
-							err = ERR_READ_USER_INPUT_ERROR.New()
-							return
-						} else if line == 'n' || line == 'N' {
-							continue
-						} else if line == 'y' || line == 'Y' {
-
-						} else {
-							continue
-						}
-					}
+		var cmds []redisCommand
+		if cmds, err = getPushCommands(data, originValue); err != nil {
+			return
+		}
+		commands = append(commands, cmds...)
+		for _, cmd := range cmds {
+			planOps = append(planOps, commandOperation(cmd, data.Type))
+		}
+
+		// the following fields of the same key will see the new type
+		if !keyTypeMatchd {
+			snapshot[data.Key] = redisValue{Type: exceptType, Fields: map[string]string{}}
+		}
+
+		pushed += 1
+		if !dryRun {
+			printOperation(viewDetails, pushOperation(data))
+		}
+	}
+
+	// the ttl of the keys declined to overwrite are kept as they are
+	ttlKeys := []string{}
+	for _, key := range pushedKeys {
+		if !declined[key] {
+			ttlKeys = append(ttlKeys, key)
+		}
+	}
+
+	var ttlCommands []redisCommand
+	if ttlCommands, err = getTTLCommands(ttlKeys, commands); err != nil {
+		return
+	}
+
+	// the prune is confirmed before applying, so it could be in the same
+	// transaction of push
+	pruneCache := []PushData{}
+	if prune {
+		// the keys to prune are read on the connection of transaction, and
+		// watched before they are read
+		var redisData, localData map[string][]PushData
+		if redisData, err = readRedisData(pipe, watch && !dryRun); err != nil {
+			return
+		}
+
+		if localData, err = getLocalData(); err != nil {
+			return
+		}
+
+		if pruneCache = getPruneData(redisData, localData); len(pruneCache) > 0 {
+			if !dryRun {
+				for _, data := range pruneCache {
+					printOperation(true, pruneOperation(data))
 				}
 			}
-		}
 
-		if exceptType == "string" {
-			if e := client.Set(data.Key, []byte(data.Value)); e != nil {
-				err = ERR_SET_REDIS_DATA_ERROR.New(errors.Params{"key": data.Key, "value": data.Value, "err": e})
+			if confirmed, e := prompt.Confirm("The keys and fields above are not exist in local, do you want delete them from redis"); e != nil {
+				err = e
 				return
+			} else if !confirmed {
+				pruneCache = []PushData{}
 			}
+		}
+	}
+
+	pruneCommands := getPruneCommands(pruneCache)
 
-			pushed += 1
-			if viewDetails {
-				fmt.Printf("[SET]\t '%s' '%v' \n", data.Key, data.Value)
+	if dryRun {
+		for _, cmd := range ttlCommands {
+			planOps = append(planOps, expireOperation(cmd))
+		}
+
+		for _, data := range pruneCache {
+			planOps = append(planOps, pruneOperation(data))
+		}
+
+		printPushPlan(planOps, pushed, len(ttlCommands), len(pruneCommands), prune)
+		return
+	}
+
+	if atomic {
+		transaction := append(append(commands, ttlCommands...), pruneCommands...)
+		if len(transaction) > 0 {
+			if err = pipe.Exec(transaction); err != nil {
+				return
 			}
-		} else {
-			if _, e := client.Hset(data.Key, data.Field, []byte(data.Value)); e != nil {
-				err = ERR_HSET_REDIS_DATA_ERROR.New(errors.Params{"key": data.Key, "field": data.Field, "value": data.Value, "err": e})
+
+			printSummary(map[string]interface{}{"transaction_commands": len(transaction)},
+				"transaction committed, %d commands applied\n", len(transaction))
+		}
+	} else {
+		for _, cmds := range [][]redisCommand{commands, ttlCommands, pruneCommands} {
+			if err = pipe.Do(cmds); err != nil {
 				return
 			}
+		}
+	}
+
+	for _, cmd := range ttlCommands {
+		printOperation(viewDetails, expireOperation(cmd))
+	}
+
+	if !prune {
+		printSummary(map[string]interface{}{"ignored": ignore, "pushed": pushed, "total": total},
+			"ignored: %d, pushed: %d, total: %d\n", ignore, pushed, total)
+		return
+	}
+
+	pruned := len(pruneCommands)
+	printSummary(map[string]interface{}{"ignored": ignore, "pushed": pushed, "pruned": pruned, "total": total},
+		"ignored: %d, pushed: %d, pruned: %d, total: %d\n", ignore, pushed, pruned, total)
+}
+
+// the keys exist in redis but not in local will be deleted, and
+// so as the fields of hash
+func getPruneData(redisData, localData map[string][]PushData) []PushData {
+	pruneCache := []PushData{}
+
+	_, removed, _ := diffData(localData, redisData)
 
-			pushed += 1
-			if viewDetails {
-				fmt.Printf("[HSET]\t '%s' '%s' '%v' \n", data.Key, data.Field, data.Value)
+	keyPruned := map[string]bool{}
+	for _, item := range removed {
+		localItems, exist := localData[item.Key]
+		if !exist {
+			if !keyPruned[item.Key] {
+				keyPruned[item.Key] = true
+				pruneCache = append(pruneCache, PushData{Key: item.Key, Type: item.Type})
 			}
+			continue
+		}
+
+		if item.Type == "hash" && localItems[0].Type == "hash" {
+			pruneCache = append(pruneCache, PushData{Key: item.Key, Field: item.Field, Type: item.Type})
+		}
+	}
+
+	return pruneCache
+}
+
+func getPruneCommands(pruneCache []PushData) []redisCommand {
+	commands := []redisCommand{}
+	for _, data := range pruneCache {
+		if data.Field == "" {
+			commands = append(commands, redisCommand{Name: "DEL", Args: []interface{}{data.Key}})
+		} else {
+			commands = append(commands, redisCommand{Name: "HDEL", Args: []interface{}{data.Key, data.Field}})
 		}
 	}
-	fmt.Printf("ignored: %d, pushed: %d, total: %d\n", ignore, pushed, total)
+	return commands
 }
 
 func cmdCommit(c *cli.Context) {
@@ -454,6 +684,7 @@ func cmdPull(c *cli.Context) {
 
 	configFile := c.String("config")
 	token := c.String("token")
+	dryRun := c.Bool("dry-run")
 
 	if token == "" {
 		token = getLocalSyncToken()
@@ -463,6 +694,22 @@ func cmdPull(c *cli.Context) {
 		return
 	}
 
+	if err = selectRemote(c.String("remote")); err != nil {
+		return
+	}
+
+	if err = checkRedisHealth(); err != nil {
+		return
+	}
+
+	if count := c.Int("scan-count"); count > 0 {
+		conf.Redis.ScanCount = count
+	}
+
+	if patterns := c.StringSlice("match"); len(patterns) > 0 {
+		conf.Include = patterns
+	}
+
 	redisToken := ""
 	redisTokenExist := false
 
@@ -475,7 +722,7 @@ func cmdPull(c *cli.Context) {
 			err = ERR_SYNC_TOKEN_NOT_MATCH.New()
 			return
 		}
-	} else {
+	} else if !dryRun {
 		if err = initSyncTokenOnNotExist(redisToken); err != nil {
 			return
 		}
@@ -490,42 +737,15 @@ func cmdPull(c *cli.Context) {
 		return
 	}
 
-	needAddToLocal := []PushData{}
-
-	for key, items := range redisData {
-		if _, exist := localData[key]; !exist {
-			needAddToLocal = append(needAddToLocal, items...)
-		}
+	if dryRun {
+		printPullPlan(redisData, localData)
+		return
 	}
 
-	added := len(needAddToLocal)
-
-	needDelToLocal := []PushData{}
-
-	for key, items := range localData {
-		if _, exist := redisData[key]; !exist {
-			needDelToLocal = append(needDelToLocal, items...)
-		}
-	}
+	needAddToLocal, needDelToLocal, valueChanged := diffData(redisData, localData)
 
+	added := len(needAddToLocal)
 	deleted := len(needDelToLocal)
-
-	valueChanged := []PushData{}
-
-	for key, redisVals := range redisData {
-		if vals, exist := localData[key]; exist {
-			for _, redisItem := range redisVals {
-				for _, localItem := range vals {
-					if redisItem.Key == localItem.Key &&
-						redisItem.Field == localItem.Field &&
-						redisItem.Value != localItem.Value {
-						valueChanged = append(valueChanged, redisItem)
-					}
-				}
-			}
-		}
-	}
-
 	updated := len(valueChanged)
 
 	if !repo.IsClean() {
@@ -550,7 +770,21 @@ func cmdPull(c *cli.Context) {
 		return
 	}
 
-	fmt.Printf("update: %d, delete: %d, add: %d\n", updated, deleted, added)
+	redisKeys := []string{}
+	for key := range redisData {
+		redisKeys = append(redisKeys, key)
+	}
+
+	if err = pullTTLs(redisKeys); err != nil {
+		return
+	}
+
+	for _, op := range pullOperations(needAddToLocal, needDelToLocal, valueChanged) {
+		printOperation(viewDetails, op)
+	}
+
+	printSummary(map[string]interface{}{"update": updated, "delete": deleted, "add": added},
+		"update: %d, delete: %d, add: %d\n", updated, deleted, added)
 }
 
 func cmdInit(c *cli.Context) {
@@ -636,9 +870,10 @@ func cmdInit(c *cli.Context) {
 }
 
 func initSyncTokenOnNotExist(token string) (err error) {
-	if _, e := os.Stat(".redis_sync/token"); e != nil {
+	if _, e := os.Stat(syncTokenFile()); e != nil {
 		if os.IsNotExist(e) {
-			if e := ioutil.WriteFile(".redis_sync/token", []byte(token), 0644); e != nil {
+			os.MkdirAll(filepath.Dir(syncTokenFile()), 0766)
+			if e := ioutil.WriteFile(syncTokenFile(), []byte(token), 0644); e != nil {
 				err = ERR_WRITE_SYNC_TOKEN_FAILED.New(errors.Params{"err": e})
 				return
 			}
@@ -648,47 +883,45 @@ func initSyncTokenOnNotExist(token string) (err error) {
 }
 
 func getLocalSyncToken() string {
-	tk, _ := ioutil.ReadFile(".redis_sync/token")
+	tk, _ := ioutil.ReadFile(syncTokenFile())
 	return string(tk)
 }
 
 func getRedisSyncToken() (token string, exist bool, err error) {
-	client := redis.Client{
-		Addr:        conf.Redis.Address,
-		Db:          conf.Redis.Db,
-		Password:    conf.Redis.Auth,
-		MaxPoolSize: 3,
+	var conn redigo.Conn
+	if conn, err = dialRedis(); err != nil {
+		return
 	}
+	defer conn.Close()
 
-	if exist, err = client.Exists(_REDIS_SYNC_TOKEN_KEY); err != nil {
-		err = ERR_GET_REDIS_SYNC_TOKEN_FAILED.New(errors.Params{"err": err})
+	if exist, err = redigo.Bool(conn.Do("EXISTS", _REDIS_SYNC_TOKEN_KEY)); err != nil {
+		err = redisCommandError("EXISTS", err, ERR_GET_REDIS_SYNC_TOKEN_FAILED.New(errors.Params{"err": err}))
 		return
 	} else if !exist {
 		return "", false, nil
 	} else {
-		if bToken, e := client.Get(_REDIS_SYNC_TOKEN_KEY); e != nil {
-			err = ERR_GET_REDIS_SYNC_TOKEN_FAILED.New(errors.Params{"err": e})
+		if strToken, e := redigo.String(conn.Do("GET", _REDIS_SYNC_TOKEN_KEY)); e != nil {
+			err = redisCommandError("GET", e, ERR_GET_REDIS_SYNC_TOKEN_FAILED.New(errors.Params{"err": e}))
 			return
 		} else {
-			return string(bToken), true, nil
+			return strToken, true, nil
 		}
 	}
 }
 
 func pushSyncToken(token string) (err error) {
-	client := redis.Client{
-		Addr:        conf.Redis.Address,
-		Db:          conf.Redis.Db,
-		Password:    conf.Redis.Auth,
-		MaxPoolSize: 3,
+	var conn redigo.Conn
+	if conn, err = dialRedis(); err != nil {
+		return
 	}
+	defer conn.Close()
 
-	if exist, e := client.Exists(_REDIS_SYNC_TOKEN_KEY); e != nil {
-		err = ERR_GET_REDIS_SYNC_TOKEN_FAILED.New(errors.Params{"err": e})
+	if exist, e := redigo.Bool(conn.Do("EXISTS", _REDIS_SYNC_TOKEN_KEY)); e != nil {
+		err = redisCommandError("EXISTS", e, ERR_GET_REDIS_SYNC_TOKEN_FAILED.New(errors.Params{"err": e}))
 		return
 	} else if !exist {
-		if e := client.Set(_REDIS_SYNC_TOKEN_KEY, []byte(token)); e != nil {
-			err = ERR_SYNC_TOKEN_TO_REDIS_FAILED.New(errors.Params{"err": e})
+		if _, e := conn.Do("SET", _REDIS_SYNC_TOKEN_KEY, token); e != nil {
+			err = redisCommandError("SET", e, ERR_SYNC_TOKEN_TO_REDIS_FAILED.New(errors.Params{"err": e}))
 			return
 		}
 	} else {
@@ -716,7 +949,7 @@ func addDataToLocal(data []PushData) (err error) {
 
 func removeLocalData(data []PushData) (err error) {
 	for _, d := range data {
-		if d.Field == "" {
+		if d.Type == "string" {
 			var vals map[string]interface{}
 			if vals, err = readDataFile("."); err != nil {
 				return
@@ -727,24 +960,55 @@ func removeLocalData(data []PushData) (err error) {
 			if err = writeDataFile(".", vals); err != nil {
 				return
 			}
+		} else if d.Type == "hash" {
+			if err = removeLocalHashField(d); err != nil {
+				return
+			}
 		} else {
-			if _, e := os.Stat(d.Key); e != nil {
-				if !os.IsNotExist(e) {
-					err = ERR_GET_KEY_DIR_FAILED.New(errors.Params{"err": e})
-					return
-				}
-			} else {
-				if e := os.RemoveAll(d.Key); e != nil {
-					err = ERR_REMOVE_LOCAL_HKEY_FAILED.New(errors.Params{"err": e})
-					return
-				}
+			// only the file of the type is removed, the key may have changed
+			// its type, and the data file of new type is in the same dir
+			if e := os.Remove(d.Key + "/" + d.Type); e != nil && !os.IsNotExist(e) {
+				err = ERR_REMOVE_LOCAL_HKEY_FAILED.New(errors.Params{"err": e})
+				return
 			}
+
+			os.Remove(d.Key)
 		}
 	}
 
 	return
 }
 
+func removeLocalHashField(d PushData) (err error) {
+	if _, e := os.Stat(d.Key + "/data"); e != nil {
+		if !os.IsNotExist(e) {
+			err = ERR_GET_KEY_DIR_FAILED.New(errors.Params{"err": e})
+		}
+		return
+	}
+
+	var vals map[string]interface{}
+	if vals, err = readDataFile(d.Key); err != nil {
+		return
+	}
+
+	delete(vals, d.Field)
+
+	if len(vals) > 0 {
+		return writeDataFile(d.Key, vals)
+	}
+
+	if e := os.Remove(d.Key + "/data"); e != nil {
+		err = ERR_REMOVE_LOCAL_HKEY_FAILED.New(errors.Params{"err": e})
+		return
+	}
+
+	// the key dir may still have other data files
+	os.Remove(d.Key)
+
+	return
+}
+
 func updateLocalData(data []PushData) (err error) {
 	for _, d := range data {
 		if err = setLocalDataValue(d); err != nil {
@@ -833,6 +1097,13 @@ func setLocalDataValue(data PushData) (err error) {
 		return
 	}
 
+	switch data.Type {
+	case "list", "set":
+		return setLocalListValue(data)
+	case "zset":
+		return setLocalZsetValue(data)
+	}
+
 	keyType := ""
 
 	if data.Field == "" {
@@ -910,6 +1181,49 @@ func setLocalDataValue(data PushData) (err error) {
 	return
 }
 
+func setLocalListValue(data PushData) (err error) {
+	keyType, _ := conf.KeyType(data.Key)
+
+	var strVals []string
+	if strVals, err = unmarshalStringArray(data.Value); err != nil {
+		err = ERR_COULD_NOT_CONV_VAL_TO_ARRAY.New(errors.Params{"val": data.Value, "err": err})
+		return
+	}
+
+	vals := []interface{}{}
+	for _, strVal := range strVals {
+		var val interface{}
+		if val, err = getTypedVal(keyType, strVal); err != nil {
+			return
+		}
+		vals = append(vals, val)
+	}
+
+	os.MkdirAll(data.Key, 0766)
+
+	if err = writeCollectionFile(data.Key, data.Type, vals); err != nil {
+		return
+	}
+
+	return
+}
+
+func setLocalZsetValue(data PushData) (err error) {
+	vals := map[string]zsetScore{}
+	if e := json.Unmarshal([]byte(data.Value), &vals); e != nil {
+		err = ERR_COULD_NOT_CONV_VAL_TO_MAP.New(errors.Params{"val": data.Value, "err": e})
+		return
+	}
+
+	os.MkdirAll(data.Key, 0766)
+
+	if err = writeCollectionFile(data.Key, data.Type, vals); err != nil {
+		return
+	}
+
+	return
+}
+
 func initDataFileOnNotExist(dir string) (err error) {
 	if dir != "." {
 		os.MkdirAll(dir, 0766)
@@ -961,63 +1275,207 @@ func writeDataFile(dir string, vals map[string]interface{}) (err error) {
 	return
 }
 
-func getRedisData() (ret map[string][]PushData, err error) {
+func writeCollectionFile(dir string, keyType string, vals interface{}) (err error) {
+	datafile := dir + "/" + keyType
 
-	client := redis.Client{
-		Addr:        conf.Redis.Address,
-		Db:          conf.Redis.Db,
-		Password:    conf.Redis.Auth,
-		MaxPoolSize: 3,
+	if data, e := json.MarshalIndent(vals, "", "    "); e != nil {
+		err = ERR_SERIALIZE_DATAFILE_FAILED.New(errors.Params{"fileName": datafile, "err": e})
+		return
+	} else if e := ioutil.WriteFile(datafile, data, 0644); e != nil {
+		err = ERR_SAVE_DATAFILE_FAILED.New(errors.Params{"fileName": datafile, "err": e})
+		return
 	}
 
-	redisData := make(map[string][]PushData)
-	if keys, e := client.Keys("*"); e != nil {
-		err = ERR_GET_REDIS_KEYS_FAILED.New(errors.Params{"err": err})
+	return
+}
+
+func parseCollectionData(key string, datafile string, keyType string, data []byte) (pData PushData, err error) {
+	if keyType == "zset" {
+		return parseZsetData(key, datafile, data)
+	}
+
+	return parseListData(key, datafile, keyType, data)
+}
+
+func parseListData(key string, listfile string, keyType string, data []byte) (pData PushData, err error) {
+	vals := []interface{}{}
+
+	if e := json.Unmarshal(data, &vals); e != nil {
+		err = ERR_PARSE_DATAFILE_ERROR.New(errors.Params{"fileName": listfile, "err": e})
 		return
-	} else {
-		for _, key := range keys {
-			if keyType, e := client.Type(key); e != nil {
+	}
+
+	dockeyValType, keyValTypeExist := conf.KeyType(key)
+
+	strVals := []string{}
+	for i, v := range vals {
+		if v == nil {
+			// redis has no null member of list and set
+			err = ERR_PARSE_DATAFILE_ERROR.New(errors.Params{"fileName": listfile, "err": fmt.Sprintf("the element %d is null", i)})
+			return
+		}
+
+		if keyValTypeExist {
+			dataValType := getValType(v)
+			if dataValType != dockeyValType {
+				err = ERR_KEY_VAL_TYPE_NOT_MATCH_TO_CONF.New(
+					errors.Params{
+						"key":   key,
+						"eType": dataValType,
+						"type":  dockeyValType,
+					},
+				)
 				return
-			} else {
-				if keyType == "string" {
-					val := ""
-					if bVal, e := client.Get(key); e != nil {
-						err = ERR_GET_REDIS_VALUE_ERROR.New(errors.Params{"key": key, "err": e})
-						return
-					} else {
-						val = string(bVal)
-					}
+			}
+		}
 
-					redisData[key] = []PushData{PushData{
-						Key:   key,
-						Value: val,
-					}}
-				} else if keyType == "hash" {
-					fieldValues := map[string]string{}
+		if strV, e := serializeObject(v); e != nil {
+			err = ERR_COULD_NOT_CONV_VAL_TO_STRING.New(errors.Params{"key": key, "err": e})
+			return
+		} else {
+			strVals = append(strVals, strV)
+		}
+	}
 
-					if e := client.Hgetall(key, &fieldValues); e != nil {
-						err = ERR_GET_REDIS_VALUE_ERROR.New(errors.Params{"key": key, "err": e})
-						return
-					}
+	// the members of set are unique in redis, so the duplicated members of
+	// local are taken as one
+	if keyType == "set" {
+		sort.Strings(strVals)
 
-					for field, value := range fieldValues {
-						if vals, exist := redisData[key]; exist {
-							vals = append(vals, PushData{
-								Key:   key,
-								Field: field,
-								Value: value,
-							})
-							redisData[key] = vals
-						} else {
-							vals := append([]PushData{PushData{
-								Key:   key,
-								Field: field,
-								Value: value,
-							}})
-							redisData[key] = vals
-						}
-					}
+		uniqueVals := []string{}
+		for i, strV := range strVals {
+			if i == 0 || strV != strVals[i-1] {
+				uniqueVals = append(uniqueVals, strV)
+			}
+		}
+		strVals = uniqueVals
+	}
+
+	strValue := ""
+	if strValue, err = serializeStrings(strVals); err != nil {
+		err = ERR_COULD_NOT_CONV_VAL_TO_STRING.New(errors.Params{"key": key, "err": err})
+		return
+	}
+
+	pData = PushData{Key: key, Value: strValue, Type: keyType}
+
+	return
+}
+
+func parseZsetData(key string, zsetfile string, data []byte) (pData PushData, err error) {
+	vals := map[string]zsetScore{}
+
+	if e := json.Unmarshal(data, &vals); e != nil {
+		err = ERR_PARSE_DATAFILE_ERROR.New(errors.Params{"fileName": zsetfile, "err": e})
+		return
+	}
+
+	if bValue, e := json.Marshal(vals); e != nil {
+		err = ERR_COULD_NOT_CONV_VAL_TO_STRING.New(errors.Params{"key": key, "err": e})
+		return
+	} else {
+		pData = PushData{Key: key, Value: string(bValue), Type: "zset"}
+	}
+
+	return
+}
+
+// zsetScore is the score of zset member in json, the infinite scores of
+// redis are not json numbers, they are the strings "+inf" and "-inf"
+type zsetScore float64
+
+func (p zsetScore) String() string {
+	switch {
+	case math.IsInf(float64(p), 1):
+		return "+inf"
+	case math.IsInf(float64(p), -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(float64(p), 'g', -1, 64)
+}
+
+func (p zsetScore) MarshalJSON() ([]byte, error) {
+	if math.IsInf(float64(p), 0) {
+		return json.Marshal(p.String())
+	}
+	return json.Marshal(float64(p))
+}
+
+func (p *zsetScore) UnmarshalJSON(data []byte) (err error) {
+	var str string
+	if e := json.Unmarshal(data, &str); e != nil {
+		return json.Unmarshal(data, (*float64)(p))
+	}
+
+	switch strings.ToLower(str) {
+	case "+inf", "inf":
+		*p = zsetScore(math.Inf(1))
+	case "-inf":
+		*p = zsetScore(math.Inf(-1))
+	default:
+		err = errors.New(fmt.Sprintf("bad score of zset: %q, it should be a number, \"+inf\" or \"-inf\"", str))
+	}
+	return
+}
+
+func getRedisData() (ret map[string][]PushData, err error) {
+	var conn redigo.Conn
+	if conn, err = dialRedis(); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	return readRedisData(newRedisPipeline(conn, _DEFAULT_BATCH_SIZE), false)
+}
+
+// readRedisData read the keys in scope by the pipeline, the keys are watched
+// after SCAN and before they are read while watch is true, so the keys
+// changed after they were read will abort the transaction of the pipeline
+func readRedisData(pipe *redisPipeline, watch bool) (ret map[string][]PushData, err error) {
+	var keys []string
+	if keys, err = pipe.Scan(conf.ScanPattern()); err != nil {
+		return
+	}
+
+	scopedKeys := []string{}
+	for _, key := range keys {
+		if conf.InScope(key) {
+			scopedKeys = append(scopedKeys, key)
+		}
+	}
+	keys = scopedKeys
+
+	if watch {
+		if err = pipe.Watch(keys...); err != nil {
+			return
+		}
+	}
+
+	var snapshot map[string]redisValue
+	if snapshot, err = pipe.Snapshot(keys, false); err != nil {
+		return
+	}
 
+	redisData := make(map[string][]PushData)
+	for key, value := range snapshot {
+		switch value.Type {
+		case "string", "list", "set", "zset":
+			{
+				redisData[key] = []PushData{PushData{
+					Key:   key,
+					Value: value.Value,
+					Type:  value.Type,
+				}}
+			}
+		case "hash":
+			{
+				for field, fieldValue := range value.Fields {
+					redisData[key] = append(redisData[key], PushData{
+						Key:   key,
+						Field: field,
+						Value: fieldValue,
+						Type:  value.Type,
+					})
 				}
 			}
 		}
@@ -1057,6 +1515,10 @@ func getLocalData() (ret map[string][]PushData, err error) {
 		}
 
 		for k, v := range data {
+			if !conf.InScope(k) {
+				continue
+			}
+
 			if originV, exist := localData[k]; exist {
 				localData[k] = append(originV, v...)
 			} else {
@@ -1100,6 +1562,7 @@ func readLocalData(key string) (ret map[string][]PushData, err error) {
 					localData[k] = []PushData{PushData{
 						Key:   k,
 						Value: strV,
+						Type:  "string",
 					}}
 				}
 			}
@@ -1114,6 +1577,7 @@ func readLocalData(key string) (ret map[string][]PushData, err error) {
 						Key:   key,
 						Field: k,
 						Value: strV,
+						Type:  "hash",
 					})
 					localData[key] = pData
 				}
@@ -1122,6 +1586,27 @@ func readLocalData(key string) (ret map[string][]PushData, err error) {
 
 	}
 
+	if key == "." {
+		ret = localData
+		return
+	}
+
+	for _, keyType := range []string{"list", "set", "zset"} {
+		datafile := key + "/" + keyType
+		if data, e := ioutil.ReadFile(datafile); e != nil {
+			if !os.IsNotExist(e) {
+				err = ERR_READ_DATAFILE_ERROR.New(errors.Params{"fileName": keyType, "err": e})
+				return
+			}
+		} else {
+			var pData PushData
+			if pData, err = parseCollectionData(key, datafile, keyType, data); err != nil {
+				return
+			}
+			localData[key] = append(localData[key], pData)
+		}
+	}
+
 	ret = localData
 	return
 }
@@ -0,0 +1,607 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codegangsta/cli"
+)
+
+type exitCodePanic int
+
+// runCommand run the command of redis_sync as the binary does, and return
+// the exit code
+func runCommand(t *testing.T, args ...string) (code int) {
+	exit = func(code int) {
+		panic(exitCodePanic(code))
+	}
+
+	defer func() {
+		exit = os.Exit
+
+		if r := recover(); r != nil {
+			if exitCode, ok := r.(exitCodePanic); ok {
+				code = int(exitCode)
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	app := cli.NewApp()
+	app.Commands = []cli.Command{
+		commandPush(cmdPush),
+		commandPull(cmdPull),
+		commandInit(cmdInit),
+		commandDiff(cmdDiff),
+		commandCheck(cmdCheck),
+		commandRemote(cmdRemoteList, cmdRemoteAdd, cmdRemoteRemove),
+	}
+
+	app.Run(diffRemoteArgs(append([]string{"redis_sync"}, args...)))
+	return _EXIT_OK
+}
+
+// newTestSyncDir init the sync dir in a temp dir, and point the config to
+// the server
+func newTestSyncDir(t *testing.T, server *fakeRedisServer) string {
+	return initTestSyncDir(t, `{"redis": {"address": "`+server.Address()+`", "db": 0, "auth": ""}}`)
+}
+
+// initTestSyncDir init the sync dir in a temp dir with the config
+func initTestSyncDir(t *testing.T, config string) string {
+	dir := t.TempDir()
+
+	workDir, _ := os.Getwd()
+	if e := os.Chdir(dir); e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(func() { os.Chdir(workDir) })
+
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(name, "redis_sync")
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(name, "redis_sync@localhost")
+	}
+
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	if code := runCommand(t, "init", "--token", "test-token"); code != _EXIT_OK {
+		t.Fatalf("init exit with %d", code)
+	}
+
+	writeTestFile(t, "redis_sync.conf", config)
+	commitTestFiles(t)
+
+	return dir
+}
+
+func resetGlobals() {
+	if redisPool != nil {
+		redisPool.Close()
+	}
+
+	redisPool = nil
+	conf = syncConfig{}
+	currentRemote = ""
+	sentinelMaster = ""
+	viewDetails = false
+	outputJSON = false
+	output = commandOutput{Operations: []outputOperation{}, Summary: map[string]interface{}{}}
+}
+
+func writeTestFile(t *testing.T, name, content string) {
+	os.MkdirAll(filepath.Dir(name), 0755)
+	if e := ioutil.WriteFile(name, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func commitTestFiles(t *testing.T) {
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", "test"}} {
+		if out, e := exec.Command("git", args...).CombinedOutput(); e != nil {
+			t.Fatalf("git %v failed: %v, %s", args, e, out)
+		}
+	}
+}
+
+// redisKey get the value of key from the store of server
+func redisKey(server *fakeRedisServer, key string) *memoryValue {
+	server.store.Lock()
+	defer server.store.Unlock()
+
+	return server.store.Dbs[0][key]
+}
+
+func setRedisKey(server *fakeRedisServer, key string, value *memoryValue) {
+	server.store.Lock()
+	defer server.store.Unlock()
+
+	// the key is modified for the watching connections
+	server.store.version++
+
+	if value == nil {
+		delete(server.store.Dbs[0], key)
+		return
+	}
+	value.version = server.store.version
+	server.store.Dbs[0][key] = value
+}
+
+// assertInSync check the data of local and redis are the same
+func assertInSync(t *testing.T) {
+	redisData, e := getRedisData()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	localData, e := getLocalData()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if added, removed, changed := diffData(localData, redisData); len(added)+len(removed)+len(changed) > 0 {
+		t.Errorf("local and redis are different, added: %v, removed: %v, changed: %v", added, removed, changed)
+	}
+}
+
+func writeTestData(t *testing.T) {
+	writeTestFile(t, "data", `{"k1": "v1", "k2": "v2"}`)
+	writeTestFile(t, "h/data", `{"f1": "a", "f2": "b"}`)
+	writeTestFile(t, "l/list", `["x", "y", "x"]`)
+	writeTestFile(t, "s/set", `["b", "a"]`)
+	writeTestFile(t, "z/zset", `{"m": 1.5, "n": 2}`)
+	commitTestFiles(t)
+}
+
+func TestPushPullRoundTrip(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+	writeTestData(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	if value := redisKey(server, "k1"); value == nil || value.Type != "string" || value.Value != "v1" {
+		t.Errorf("k1 = %+v, want string v1", value)
+	}
+	if value := redisKey(server, "h"); value == nil || !reflect.DeepEqual(value.Fields, map[string]string{"f1": "a", "f2": "b"}) {
+		t.Errorf("h = %+v, want hash of f1, f2", value)
+	}
+	if value := redisKey(server, "l"); value == nil || !reflect.DeepEqual(value.List, []string{"x", "y", "x"}) {
+		t.Errorf("l = %+v, want list of x, y, x", value)
+	}
+	if value := redisKey(server, "s"); value == nil || value.Type != "set" || len(value.Scores) != 2 {
+		t.Errorf("s = %+v, want set of a, b", value)
+	}
+	if value := redisKey(server, "z"); value == nil || !reflect.DeepEqual(value.Scores, map[string]float64{"m": 1.5, "n": 2}) {
+		t.Errorf("z = %+v, want zset of m: 1.5, n: 2", value)
+	}
+
+	assertInSync(t)
+
+	setRedisKey(server, "k1", &memoryValue{Type: "string", Value: "changed"})
+	setRedisKey(server, "k2", nil)
+	setRedisKey(server, "h", &memoryValue{Type: "hash", Fields: map[string]string{"f1": "a", "f3": "c"}})
+	setRedisKey(server, "s", &memoryValue{Type: "set", Scores: map[string]float64{"c": 0, "a": 0}})
+	setRedisKey(server, "n", &memoryValue{Type: "list", List: []string{"1"}})
+
+	if code := runCommand(t, "pull"); code != _EXIT_OK {
+		t.Fatalf("pull exit with %d", code)
+	}
+
+	assertInSync(t)
+
+	data, _ := ioutil.ReadFile("s/set")
+	if members, _ := unmarshalStringArray(string(data)); !reflect.DeepEqual(members, []string{"a", "c"}) {
+		t.Errorf("s/set = %s, want the sorted members", data)
+	}
+}
+
+func TestPullTypeChanged(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+	writeTestData(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	setRedisKey(server, "k1", &memoryValue{Type: "list", List: []string{"a", "b"}})
+	setRedisKey(server, "l", &memoryValue{Type: "set", Scores: map[string]float64{"x": 0, "y": 0}})
+
+	if code := runCommand(t, "pull"); code != _EXIT_OK {
+		t.Fatalf("pull exit with %d", code)
+	}
+
+	if data, _ := ioutil.ReadFile("data"); strings.Contains(string(data), "k1") {
+		t.Errorf("data = %s, want k1 removed", data)
+	}
+
+	for name, exist := range map[string]bool{"k1/list": true, "l/list": false, "l/set": true} {
+		if _, e := os.Stat(name); (e == nil) != exist {
+			t.Errorf("stat %s = %v, want exist: %v", name, e, exist)
+		}
+	}
+
+	assertInSync(t)
+	commitTestFiles(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push after pull exit with %d", code)
+	}
+
+	if value := redisKey(server, "k1"); value == nil || value.Type != "list" {
+		t.Errorf("k1 = %+v, want list", value)
+	}
+	if value := redisKey(server, "l"); value == nil || value.Type != "set" {
+		t.Errorf("l = %+v, want set", value)
+	}
+}
+
+func TestPushTypeMismatch(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+	writeTestData(t)
+
+	setRedisKey(server, "h", &memoryValue{Type: "string", Value: "plain"})
+
+	if code := runCommand(t, "push", "--on-conflict", "fail"); code != _EXIT_CONFLICT {
+		t.Fatalf("push with conflict exit with %d, want %d", code, _EXIT_CONFLICT)
+	}
+
+	if value := redisKey(server, "h"); value == nil || value.Type != "string" || value.Value != "plain" {
+		t.Errorf("h = %+v, want the string not changed", value)
+	}
+
+	if code := runCommand(t, "push", "--no-overwrite"); code != _EXIT_OK {
+		t.Fatalf("push --no-overwrite exit with %d", code)
+	}
+
+	if value := redisKey(server, "h"); value == nil || value.Type != "string" {
+		t.Errorf("h = %+v, want the string skipped", value)
+	}
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push --yes exit with %d", code)
+	}
+
+	if value := redisKey(server, "h"); value == nil || value.Type != "hash" || len(value.Fields) != 2 {
+		t.Errorf("h = %+v, want the hash overwritten", value)
+	}
+
+	assertInSync(t)
+}
+
+func TestSyncToken(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+	writeTestData(t)
+
+	if e := initalConfig(""); e != nil {
+		t.Fatal(e)
+	}
+
+	if _, exist, e := getRedisSyncToken(); e != nil || exist {
+		t.Fatalf("getRedisSyncToken() = %v, %v, want not exist", exist, e)
+	}
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	if token, exist, e := getRedisSyncToken(); e != nil || !exist || token != "test-token" {
+		t.Fatalf("getRedisSyncToken() = %q, %v, %v, want test-token", token, exist, e)
+	}
+
+	if e := pushSyncToken("other-token"); !ERR_REDIS_ALREADY_HAVE_TOKEN.IsEqual(e) {
+		t.Errorf("pushSyncToken() = %v, want ERR_REDIS_ALREADY_HAVE_TOKEN", e)
+	}
+
+	setRedisKey(server, _REDIS_SYNC_TOKEN_KEY, &memoryValue{Type: "string", Value: "other-token"})
+	setRedisKey(server, "k1", &memoryValue{Type: "string", Value: "changed"})
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_TOKEN {
+		t.Errorf("push with other token exit with %d, want %d", code, _EXIT_TOKEN)
+	}
+
+	if value := redisKey(server, "k1"); value.Value != "changed" {
+		t.Errorf("k1 = %q, want not pushed", value.Value)
+	}
+
+	if code := runCommand(t, "pull"); code != _EXIT_TOKEN {
+		t.Errorf("pull with other token exit with %d, want %d", code, _EXIT_TOKEN)
+	}
+
+	if data, _ := ioutil.ReadFile("data"); string(data) != `{"k1": "v1", "k2": "v2"}` {
+		t.Errorf("data = %s, want not pulled", data)
+	}
+
+	if code := runCommand(t, "push", "--yes", "--token", "other-token"); code != _EXIT_OK {
+		t.Errorf("push with --token exit with %d", code)
+	}
+}
+
+func TestParseListDataWithNull(t *testing.T) {
+	for _, keyType := range []string{"list", "set"} {
+		if _, e := parseListData("l", "l/"+keyType, keyType, []byte(`["a", null]`)); !ERR_PARSE_DATAFILE_ERROR.IsEqual(e) {
+			t.Errorf("parseListData(%s) = %v, want ERR_PARSE_DATAFILE_ERROR", keyType, e)
+		}
+	}
+
+	if _, e := serializeObject(nil); e == nil {
+		t.Errorf("serializeObject(nil) = nil, want error")
+	}
+}
+
+func TestGetCommitData(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+	writeTestData(t)
+
+	// git writes the trace to stderr, it should not be in the tar
+	t.Setenv("GIT_TRACE", "1")
+
+	commitData, e := getCommitData(_HEAD_COMMIT)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	localData, e := getLocalData()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if added, removed, changed := diffData(localData, commitData); len(added)+len(removed)+len(changed) > 0 {
+		t.Errorf("commit and local are different, added: %v, removed: %v, changed: %v", added, removed, changed)
+	}
+
+	if _, e := getCommitData("not-exist"); !ERR_READ_COMMIT_DATA_FAILED.IsEqual(e) || !strings.Contains(e.Error(), "not-exist") {
+		t.Errorf("getCommitData(not-exist) = %v, want ERR_READ_COMMIT_DATA_FAILED with the stderr of git", e)
+	}
+}
+
+func TestPushAtomicWithTTLAndPrune(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+	writeTestData(t)
+
+	writeTestFile(t, _TTL_FILE, `{"k1": 3600, "h": 60}`)
+	commitTestFiles(t)
+
+	setRedisKey(server, "h", &memoryValue{Type: "hash", Fields: map[string]string{"f1": "a", "f2": "b", "f3": "c"}})
+	setRedisKey(server, "old", &memoryValue{Type: "string", Value: "v"})
+	setRedisKey(server, _REDIS_SYNC_TOKEN_KEY, &memoryValue{Type: "string", Value: "test-token"})
+	server.Commands()
+
+	if code := runCommand(t, "push", "--atomic", "--prune", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	// the writes, the EXPIRE and the prune are all between MULTI and EXEC
+	writes := map[string]bool{"SET": true, "HSET": true, "RPUSH": true, "SADD": true, "ZADD": true, "DEL": true, "HDEL": true, "EXPIRE": true}
+	inMulti := false
+	applied := map[string]bool{}
+	for _, cmd := range server.Commands() {
+		switch {
+		case cmd == "MULTI":
+			inMulti = true
+		case cmd == "EXEC":
+			inMulti = false
+		case writes[cmd]:
+			if !inMulti {
+				t.Errorf("%s is out of the transaction", cmd)
+			}
+			applied[cmd] = true
+		}
+	}
+
+	for _, cmd := range []string{"SET", "EXPIRE", "DEL", "HDEL"} {
+		if !applied[cmd] {
+			t.Errorf("%s is not applied", cmd)
+		}
+	}
+
+	if value := redisKey(server, "old"); value != nil {
+		t.Errorf("old = %+v, want pruned", value)
+	}
+	if value := redisKey(server, "h"); value == nil || len(value.Fields) != 2 || value.ExpireAt == 0 {
+		t.Errorf("h = %+v, want f3 pruned and expired", value)
+	}
+	if value := redisKey(server, "k1"); value == nil || value.ExpireAt == 0 {
+		t.Errorf("k1 = %+v, want expired", value)
+	}
+
+	assertInSync(t)
+}
+
+func TestSetDuplicatedMembers(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+
+	writeTestFile(t, "s/set", `["b", "a", "b"]`)
+	commitTestFiles(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	// the set of redis is the same as local, nothing to push again
+	server.Commands()
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push again exit with %d", code)
+	}
+
+	for _, cmd := range server.Commands() {
+		if cmd == "SADD" || cmd == "SREM" {
+			t.Errorf("%s is sent while the set is not changed", cmd)
+		}
+	}
+
+	assertInSync(t)
+}
+
+func TestZsetInfiniteScores(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+
+	writeTestFile(t, "z/zset", `{"top": "+inf", "m": 1.5}`)
+	commitTestFiles(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	if value := redisKey(server, "z"); value == nil || !math.IsInf(value.Scores["top"], 1) || value.Scores["m"] != 1.5 {
+		t.Errorf("z = %+v, want top: +inf, m: 1.5", value)
+	}
+
+	setRedisKey(server, "z", &memoryValue{Type: "zset", Scores: map[string]float64{"top": math.Inf(1), "bottom": math.Inf(-1)}})
+
+	if code := runCommand(t, "pull"); code != _EXIT_OK {
+		t.Fatalf("pull exit with %d", code)
+	}
+
+	scores := map[string]interface{}{}
+	if data, e := ioutil.ReadFile("z/zset"); e != nil {
+		t.Fatal(e)
+	} else if e := json.Unmarshal(data, &scores); e != nil {
+		t.Fatal(e)
+	}
+
+	if !reflect.DeepEqual(scores, map[string]interface{}{"top": "+inf", "bottom": "-inf"}) {
+		t.Errorf("z/zset = %v, want top: +inf, bottom: -inf", scores)
+	}
+
+	assertInSync(t)
+
+	writeTestFile(t, "z/zset", `{"m": "infinity"}`)
+	commitTestFiles(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_DATA_INVALID {
+		t.Errorf("push the bad score exit with %d, want %d", code, _EXIT_DATA_INVALID)
+	}
+}
+
+func TestPushWatchPrune(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+	writeTestData(t)
+
+	setRedisKey(server, "old", &memoryValue{Type: "string", Value: "v"})
+	setRedisKey(server, _REDIS_SYNC_TOKEN_KEY, &memoryValue{Type: "string", Value: "test-token"})
+
+	// old is changed by others right before it is read for the prune
+	changed := false
+	server.store.Lock()
+	server.before = func(args []string) {
+		if !changed && len(args) > 1 && args[1] == "old" && strings.ToUpper(args[0]) != "WATCH" {
+			changed = true
+			setRedisKey(server, "old", &memoryValue{Type: "string", Value: "changed"})
+		}
+	}
+	server.store.Unlock()
+
+	if code := runCommand(t, "push", "--watch", "--prune", "--yes"); code != _EXIT_CONFLICT {
+		t.Errorf("push exit with %d, want %d", code, _EXIT_CONFLICT)
+	}
+
+	if value := redisKey(server, "old"); value == nil || value.Value != "changed" {
+		t.Errorf("old = %+v, want the change of others kept", value)
+	}
+}
+
+func TestPushPullTTL(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+	writeTestData(t)
+
+	writeTestFile(t, _TTL_FILE, `{"k1": 3600, "h": 60}`)
+	commitTestFiles(t)
+
+	// k1 is declined to overwrite, k2 is persistent in local
+	setRedisKey(server, "k1", &memoryValue{Type: "string", Value: "changed"})
+	setRedisKey(server, "k2", &memoryValue{Type: "string", Value: "v2", ExpireAt: memoryNow() + 60000})
+	setRedisKey(server, _REDIS_SYNC_TOKEN_KEY, &memoryValue{Type: "string", Value: "test-token"})
+
+	if code := runCommand(t, "push", "--no-overwrite"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	if value := redisKey(server, "k1"); value == nil || value.Value != "changed" || value.ExpireAt != 0 {
+		t.Errorf("k1 = %+v, want declined without EXPIRE", value)
+	}
+	if value := redisKey(server, "k2"); value == nil || value.ExpireAt != 0 {
+		t.Errorf("k2 = %+v, want persistent", value)
+	}
+	if value := redisKey(server, "h"); value == nil || value.ExpireAt == 0 {
+		t.Errorf("h = %+v, want expired", value)
+	}
+
+	// the ttl less than one second is rounded up
+	setRedisKey(server, "l", &memoryValue{Type: "list", List: []string{"x", "y", "x"}, ExpireAt: memoryNow() + 400})
+
+	if code := runCommand(t, "pull"); code != _EXIT_OK {
+		t.Fatalf("pull exit with %d", code)
+	}
+
+	ttls, e := readTTLFile()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if ttls["l"] != 1 || ttls["h"] != 60 {
+		t.Errorf("ttls = %v, want l: 1, h: 60", ttls)
+	}
+}
+
+func TestPushDryRunPlan(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	newTestSyncDir(t, server)
+	writeTestData(t)
+
+	writeTestFile(t, _TTL_FILE, `{"k1": 3600}`)
+	commitTestFiles(t)
+
+	setRedisKey(server, "h", &memoryValue{Type: "string", Value: "plain"})
+	setRedisKey(server, "old", &memoryValue{Type: "string", Value: "v"})
+	setRedisKey(server, _REDIS_SYNC_TOKEN_KEY, &memoryValue{Type: "string", Value: "test-token"})
+	server.Commands()
+
+	outputJSON = true
+	if code := runCommand(t, "push", "--dry-run", "--prune"); code != _EXIT_OK {
+		t.Fatalf("push --dry-run exit with %d", code)
+	}
+
+	planned := map[string]bool{}
+	for _, op := range output.Operations {
+		planned[op.Op+" "+op.Key+" "+op.Field] = true
+	}
+
+	for _, op := range []string{"DEL h ", "HSET h f1", "HSET h f2", "SET k1 ", "EXPIRE k1 ", "RPUSH l ", "DEL old "} {
+		if !planned[op] {
+			t.Errorf("%q is not in the plan %v", op, planned)
+		}
+	}
+
+	if output.Summary["expire"] != 1 || output.Summary["prune"] != 1 {
+		t.Errorf("summary = %v, want expire: 1, prune: 1", output.Summary)
+	}
+
+	for _, cmd := range server.Commands() {
+		switch cmd {
+		case "SET", "HSET", "RPUSH", "SADD", "ZADD", "DEL", "HDEL", "EXPIRE", "MULTI", "EXEC":
+			t.Errorf("%s is sent by dry run", cmd)
+		}
+	}
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogap/errors"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// memoryValue is the value of key in memory redis, the members of set are
+// stored in Scores with score 0
+type memoryValue struct {
+	Type     string
+	Value    string
+	Fields   map[string]string
+	List     []string
+	Scores   map[string]float64
+	ExpireAt int64 // unix milliseconds
+
+	version int64
+}
+
+// memoryStore is the data of memory redis, it is shared by the connections
+// of the fake server, they run the commands one by one with the lock
+type memoryStore struct {
+	Dbs map[int]map[string]*memoryValue
+
+	// denied are the commands the acl user has no permission to run
+	denied map[string]bool
+
+	sync.Mutex
+	version int64
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{Dbs: map[int]map[string]*memoryValue{0: {}}}
+}
+
+// memoryConn is the in-memory redigo.Conn, it implements the commands used by
+// redis sync, so the push, pull and the token check could be tested without
+// redis
+type memoryConn struct {
+	store *memoryStore
+	db    int
+
+	pending []interface{}
+	queued  [][]interface{}
+	multi   bool
+	aborted bool
+	watched map[string]int64
+}
+
+func dialMemoryRedis(store *memoryStore, db int) *memoryConn {
+	if store.Dbs[db] == nil {
+		store.Dbs[db] = map[string]*memoryValue{}
+	}
+
+	return &memoryConn{store: store, db: db}
+}
+
+func (p *memoryConn) Close() error {
+	return nil
+}
+
+func (p *memoryConn) Err() error {
+	return nil
+}
+
+func (p *memoryConn) Send(cmd string, args ...interface{}) error {
+	reply, err := p.do(cmd, args...)
+	if err != nil {
+		p.pending = append(p.pending, err)
+	} else {
+		p.pending = append(p.pending, reply)
+	}
+	return nil
+}
+
+func (p *memoryConn) Flush() error {
+	return nil
+}
+
+func (p *memoryConn) Receive() (reply interface{}, err error) {
+	if len(p.pending) == 0 {
+		err = errors.New("no pending command of memory redis")
+		return
+	}
+
+	reply = p.pending[0]
+	p.pending = p.pending[1:]
+
+	if e, ok := reply.(redigo.Error); ok {
+		return nil, e
+	}
+
+	return
+}
+
+// Do receive the pending replies of Send first, as same as redigo does
+func (p *memoryConn) Do(cmd string, args ...interface{}) (reply interface{}, err error) {
+	for len(p.pending) > 0 {
+		if _, e := p.Receive(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	if cmd == "" {
+		return
+	}
+
+	return p.do(cmd, args...)
+}
+
+func (p *memoryConn) do(cmd string, args ...interface{}) (reply interface{}, err error) {
+	cmd = strings.ToUpper(cmd)
+
+	strArgs := make([]string, len(args))
+	for i, arg := range args {
+		strArgs[i] = memoryArg(arg)
+	}
+
+	switch cmd {
+	case "MULTI":
+		p.multi = true
+		p.aborted = false
+		p.queued = nil
+		return "OK", nil
+	case "EXEC":
+		return p.exec()
+	case "DISCARD":
+		p.multi = false
+		p.queued = nil
+		p.watched = nil
+		return "OK", nil
+	}
+
+	// the command rejected while queued aborts the transaction
+	if p.store.denied[cmd] {
+		p.aborted = p.multi
+		return nil, redigo.Error(fmt.Sprintf("NOPERM User default has no permissions to run the '%s' command", strings.ToLower(cmd)))
+	}
+
+	if p.multi {
+		p.queued = append(p.queued, append([]interface{}{cmd}, args...))
+		return "QUEUED", nil
+	}
+
+	reply = p.run(cmd, strArgs)
+	if e, ok := reply.(redigo.Error); ok {
+		return nil, e
+	}
+
+	return
+}
+
+func (p *memoryConn) exec() (reply interface{}, err error) {
+	if !p.multi {
+		return nil, redigo.Error("ERR EXEC without MULTI")
+	}
+
+	queued := p.queued
+	watched := p.watched
+	aborted := p.aborted
+
+	p.multi = false
+	p.aborted = false
+	p.queued = nil
+	p.watched = nil
+
+	if aborted {
+		return nil, redigo.Error("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	for key, version := range watched {
+		if p.keyVersion(key) != version {
+			return nil, nil
+		}
+	}
+
+	replies := []interface{}{}
+	for _, cmd := range queued {
+		strArgs := []string{}
+		for _, arg := range cmd[1:] {
+			strArgs = append(strArgs, memoryArg(arg))
+		}
+		replies = append(replies, p.run(cmd[0].(string), strArgs))
+	}
+
+	return replies, nil
+}
+
+func (p *memoryConn) data() map[string]*memoryValue {
+	return p.store.Dbs[p.db]
+}
+
+func memoryNow() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func (p *memoryConn) lookup(key string) *memoryValue {
+	value := p.data()[key]
+	if value != nil && value.ExpireAt > 0 && value.ExpireAt <= memoryNow() {
+		delete(p.data(), key)
+		return nil
+	}
+	return value
+}
+
+func (p *memoryConn) keyVersion(key string) int64 {
+	if value := p.lookup(key); value != nil {
+		return value.version
+	}
+	return -1
+}
+
+// lookupOrCreate return the value of key with the type, it return nil while
+// the key is another type
+func (p *memoryConn) lookupOrCreate(key, keyType string) *memoryValue {
+	value := p.lookup(key)
+	if value == nil {
+		value = &memoryValue{Type: keyType}
+		p.data()[key] = value
+	} else if value.Type != keyType {
+		return nil
+	}
+
+	p.touch(value)
+	return value
+}
+
+func (p *memoryConn) touch(value *memoryValue) {
+	p.store.version++
+	value.version = p.store.version
+}
+
+func (p *memoryConn) remove(key string) bool {
+	if p.lookup(key) == nil {
+		return false
+	}
+
+	p.store.version++
+	delete(p.data(), key)
+	return true
+}
+
+func (p *memoryConn) run(cmd string, args []string) interface{} {
+	arity := map[string]int{
+		"PING": 0, "ROLE": 0, "SCAN": 1, "UNWATCH": 0,
+		"TYPE": 1, "GET": 1, "SET": 2, "EXISTS": 1, "DEL": 1, "TTL": 1, "PTTL": 1, "EXPIRE": 2, "PERSIST": 1, "WATCH": 1,
+		"HSET": 3, "HDEL": 2, "HGETALL": 1, "HSCAN": 2,
+		"LRANGE": 3, "RPUSH": 2,
+		"SADD": 2, "SREM": 2, "SSCAN": 2,
+		"ZADD": 3, "ZREM": 2, "ZSCAN": 2,
+	}
+
+	if n, exist := arity[cmd]; !exist {
+		return redigo.Error(fmt.Sprintf("ERR unknown command '%s' of memory redis", cmd))
+	} else if len(args) < n {
+		return redigo.Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+	}
+
+	wrongType := redigo.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	switch cmd {
+	case "PING":
+		return "PONG"
+	case "ROLE":
+		return []interface{}{[]byte("master")}
+	case "WATCH":
+		if p.watched == nil {
+			p.watched = map[string]int64{}
+		}
+		for _, key := range args {
+			p.watched[key] = p.keyVersion(key)
+		}
+		return "OK"
+	case "UNWATCH":
+		p.watched = nil
+		return "OK"
+	case "SCAN":
+		match := memoryScanMatch(args[1:])
+		keys := []string{}
+		for key := range p.data() {
+			if p.lookup(key) == nil {
+				continue
+			}
+			if matchKeyPattern(match, key) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return memoryScanReply(keys)
+	case "TYPE":
+		if value := p.lookup(args[0]); value != nil {
+			return value.Type
+		}
+		return "none"
+	case "GET":
+		if value := p.lookup(args[0]); value == nil {
+			return nil
+		} else if value.Type != "string" {
+			return wrongType
+		} else {
+			return []byte(value.Value)
+		}
+	case "SET":
+		if len(args) > 2 && strings.ToUpper(args[2]) == "NX" && p.lookup(args[0]) != nil {
+			return nil
+		}
+		p.remove(args[0])
+		value := p.lookupOrCreate(args[0], "string")
+		value.Value = args[1]
+		return "OK"
+	case "EXISTS":
+		count := int64(0)
+		for _, key := range args {
+			if p.lookup(key) != nil {
+				count++
+			}
+		}
+		return count
+	case "DEL":
+		count := int64(0)
+		for _, key := range args {
+			if p.remove(key) {
+				count++
+			}
+		}
+		return count
+	case "TTL", "PTTL":
+		if value := p.lookup(args[0]); value == nil {
+			return int64(-2)
+		} else if value.ExpireAt == 0 {
+			return int64(-1)
+		} else if ttl := value.ExpireAt - memoryNow(); cmd == "PTTL" {
+			return ttl
+		} else {
+			// rounded as redis does
+			return (ttl + 500) / 1000
+		}
+	case "EXPIRE":
+		seconds, e := strconv.ParseInt(args[1], 10, 64)
+		if e != nil {
+			return redigo.Error("ERR value is not an integer or out of range")
+		}
+		value := p.lookup(args[0])
+		if value == nil {
+			return int64(0)
+		}
+		p.touch(value)
+		value.ExpireAt = memoryNow() + seconds*1000
+		return int64(1)
+	case "PERSIST":
+		value := p.lookup(args[0])
+		if value == nil || value.ExpireAt == 0 {
+			return int64(0)
+		}
+		p.touch(value)
+		value.ExpireAt = 0
+		return int64(1)
+	case "HSET":
+		value := p.lookupOrCreate(args[0], "hash")
+		if value == nil {
+			return wrongType
+		}
+		if value.Fields == nil {
+			value.Fields = map[string]string{}
+		}
+		added := int64(0)
+		for i := 1; i+1 < len(args); i += 2 {
+			if _, exist := value.Fields[args[i]]; !exist {
+				added++
+			}
+			value.Fields[args[i]] = args[i+1]
+		}
+		return added
+	case "HDEL":
+		value := p.lookup(args[0])
+		if value == nil {
+			return int64(0)
+		} else if value.Type != "hash" {
+			return wrongType
+		}
+		p.touch(value)
+		removed := int64(0)
+		for _, field := range args[1:] {
+			if _, exist := value.Fields[field]; exist {
+				delete(value.Fields, field)
+				removed++
+			}
+		}
+		if len(value.Fields) == 0 {
+			p.remove(args[0])
+		}
+		return removed
+	case "HGETALL", "HSCAN":
+		value := p.lookup(args[0])
+		if value != nil && value.Type != "hash" {
+			return wrongType
+		}
+		items := []string{}
+		if value != nil {
+			for _, field := range sortedStringKeys(value.Fields) {
+				items = append(items, field, value.Fields[field])
+			}
+		}
+		if cmd == "HGETALL" {
+			return memoryBulks(items)
+		}
+		return memoryScanReply(items)
+	case "LRANGE":
+		value := p.lookup(args[0])
+		if value == nil {
+			return []interface{}{}
+		} else if value.Type != "list" {
+			return wrongType
+		}
+		start, _ := strconv.Atoi(args[1])
+		stop, _ := strconv.Atoi(args[2])
+		if start < 0 {
+			start += len(value.List)
+		}
+		if stop < 0 {
+			stop += len(value.List)
+		}
+		if start < 0 {
+			start = 0
+		}
+		if stop >= len(value.List) {
+			stop = len(value.List) - 1
+		}
+		if start > stop {
+			return []interface{}{}
+		}
+		return memoryBulks(value.List[start : stop+1])
+	case "RPUSH":
+		value := p.lookupOrCreate(args[0], "list")
+		if value == nil {
+			return wrongType
+		}
+		value.List = append(value.List, args[1:]...)
+		return int64(len(value.List))
+	case "SADD", "ZADD":
+		keyType := "set"
+		if cmd == "ZADD" {
+			keyType = "zset"
+		}
+		value := p.lookupOrCreate(args[0], keyType)
+		if value == nil {
+			return wrongType
+		}
+		if value.Scores == nil {
+			value.Scores = map[string]float64{}
+		}
+		added := int64(0)
+		if cmd == "SADD" {
+			for _, member := range args[1:] {
+				if _, exist := value.Scores[member]; !exist {
+					added++
+				}
+				value.Scores[member] = 0
+			}
+			return added
+		}
+		for i := 1; i+1 < len(args); i += 2 {
+			score, e := strconv.ParseFloat(args[i], 64)
+			if e != nil {
+				return redigo.Error("ERR value is not a valid float")
+			}
+			if _, exist := value.Scores[args[i+1]]; !exist {
+				added++
+			}
+			value.Scores[args[i+1]] = score
+		}
+		return added
+	case "SREM", "ZREM":
+		value := p.lookup(args[0])
+		if value == nil {
+			return int64(0)
+		} else if (cmd == "SREM" && value.Type != "set") || (cmd == "ZREM" && value.Type != "zset") {
+			return wrongType
+		}
+		p.touch(value)
+		removed := int64(0)
+		for _, member := range args[1:] {
+			if _, exist := value.Scores[member]; exist {
+				delete(value.Scores, member)
+				removed++
+			}
+		}
+		if len(value.Scores) == 0 {
+			p.remove(args[0])
+		}
+		return removed
+	case "SSCAN", "ZSCAN":
+		value := p.lookup(args[0])
+		if value != nil && ((cmd == "SSCAN" && value.Type != "set") || (cmd == "ZSCAN" && value.Type != "zset")) {
+			return wrongType
+		}
+		items := []string{}
+		if value != nil {
+			members := []string{}
+			for member := range value.Scores {
+				members = append(members, member)
+			}
+			sort.Strings(members)
+			for _, member := range members {
+				items = append(items, member)
+				if cmd == "ZSCAN" {
+					items = append(items, strconv.FormatFloat(value.Scores[member], 'f', -1, 64))
+				}
+			}
+		}
+		return memoryScanReply(items)
+	}
+
+	return nil
+}
+
+func memoryArg(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(arg)
+}
+
+func memoryScanMatch(args []string) string {
+	for i := 0; i+1 < len(args); i += 2 {
+		if strings.ToUpper(args[i]) == "MATCH" {
+			return args[i+1]
+		}
+	}
+	return "*"
+}
+
+// memoryScanReply return all the items in one round, so the cursor is 0
+func memoryScanReply(items []string) interface{} {
+	return []interface{}{[]byte("0"), memoryBulks(items)}
+}
+
+func memoryBulks(items []string) []interface{} {
+	bulks := []interface{}{}
+	for _, item := range items {
+		bulks = append(bulks, []byte(item))
+	}
+	return bulks
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/gogap/errors"
+)
+
+const (
+	_OUTPUT_TEXT = "text"
+	_OUTPUT_JSON = "json"
+)
+
+// outputOperation is one record of --output json, the line is the text of
+// the operation in text mode
+type outputOperation struct {
+	Op         string `json:"op"`
+	Key        string `json:"key,omitempty"`
+	OldKey     string `json:"old_key,omitempty"`
+	Field      string `json:"field,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Value      string `json:"value,omitempty"`
+	LocalValue string `json:"local_value,omitempty"`
+	RedisValue string `json:"redis_value,omitempty"`
+	OldValue   string `json:"old_value,omitempty"`
+	NewValue   string `json:"new_value,omitempty"`
+	OldType    string `json:"old_type,omitempty"`
+	NewType    string `json:"new_type,omitempty"`
+	File       string `json:"file,omitempty"`
+	TTL        *int64 `json:"ttl,omitempty"`
+	LocalTTL   *int64 `json:"local_ttl,omitempty"`
+	RedisTTL   *int64 `json:"redis_ttl,omitempty"`
+
+	Changes []valueChange `json:"changes,omitempty"`
+
+	line string
+}
+
+type outputError struct {
+	Namespace string                 `json:"namespace"`
+	Code      uint64                 `json:"code"`
+	Message   string                 `json:"message"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+}
+
+type commandOutput struct {
+	Command    string                 `json:"command"`
+	Operations []outputOperation      `json:"operations"`
+	Summary    map[string]interface{} `json:"summary"`
+	Git        *string                `json:"git,omitempty"`
+	Error      *outputError           `json:"error,omitempty"`
+}
+
+var (
+	outputJSON = false
+
+	output = commandOutput{
+		Operations: []outputOperation{},
+		Summary:    map[string]interface{}{},
+	}
+)
+
+// setOutput is the Before of app, it read the global --output
+func setOutput(c *cli.Context) error {
+	switch c.String("output") {
+	case "", _OUTPUT_TEXT:
+	case _OUTPUT_JSON:
+		outputJSON = true
+		output.Command = c.Args().First()
+	default:
+		exitError(ERR_BAD_FLAG_VALUE.New(errors.Params{"flag": "output", "value": c.String("output"), "values": "text or json"}))
+	}
+
+	return nil
+}
+
+// flushOutput print the json after the command finished, the failed command
+// print it by exitError
+func flushOutput() {
+	switch output.Command {
+	case "", "help", "h":
+		return
+	}
+
+	if outputJSON {
+		printJSONOutput()
+	}
+}
+
+func printJSONOutput() {
+	data, _ := json.MarshalIndent(output, "", "    ")
+	fmt.Println(string(data))
+}
+
+// printOperation record the operation in json mode, and print it while
+// verbose in text mode
+func printOperation(verbose bool, op outputOperation) {
+	if outputJSON {
+		output.Operations = append(output.Operations, op)
+	} else if verbose {
+		fmt.Println(op.line)
+	}
+}
+
+// printSummary merge the summary in json mode, and print the text in text
+// mode
+func printSummary(summary map[string]interface{}, format string, args ...interface{}) {
+	if outputJSON {
+		for name, value := range summary {
+			output.Summary[name] = value
+		}
+		return
+	}
+
+	fmt.Printf(format, args...)
+}
+
+func printGitOutput(gitOutput []byte) {
+	if outputJSON {
+		strOutput := string(gitOutput)
+		output.Git = &strOutput
+		return
+	}
+
+	fmt.Println(string(gitOutput))
+}
+
+// promptWriter is the writer of questions, it should not break the json of
+// stdout
+func promptWriter() io.Writer {
+	if outputJSON {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+func printErrorOutput(err error) {
+	outErr := &outputError{Namespace: REDIS_SYNC_ERR_NS, Message: err.Error()}
+
+	if errCode, ok := err.(errors.ErrCode); ok {
+		outErr.Namespace = errCode.Namespace()
+		outErr.Code = errCode.Code()
+		outErr.Params = errCode.Context()
+	}
+
+	output.Error = outErr
+	printJSONOutput()
+}
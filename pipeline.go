@@ -0,0 +1,438 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gogap/errors"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	_DEFAULT_BATCH_SIZE = 100
+	_DEFAULT_SCAN_COUNT = 100
+)
+
+type redisCommand struct {
+	Name string
+	Args []interface{}
+}
+
+// redisValue is the value of key read by snapshot, the Value of list, set
+// and zset are serialized as same as the local data, so they could be
+// compared directly
+type redisValue struct {
+	Type    string
+	Value   string
+	Fields  map[string]string
+	Unknown bool
+}
+
+// redisPipeline send the commands in batches, every batch is one round-trip
+type redisPipeline struct {
+	conn      redigo.Conn
+	batchSize int
+}
+
+func newRedisPipeline(conn redigo.Conn, batchSize int) *redisPipeline {
+	if batchSize <= 0 {
+		batchSize = _DEFAULT_BATCH_SIZE
+	}
+
+	return &redisPipeline{conn: conn, batchSize: batchSize}
+}
+
+// Run send the commands and return the replies, the error replies of redis
+// are returned as redigo.Error in replies
+func (p *redisPipeline) Run(commands []redisCommand) (replies []interface{}, err error) {
+	for start := 0; start < len(commands); start += p.batchSize {
+		end := start + p.batchSize
+		if end > len(commands) {
+			end = len(commands)
+		}
+
+		for _, cmd := range commands[start:end] {
+			// the errors of cluster routing such as the slot not covered
+			// are reported as they are
+			if e := p.conn.Send(cmd.Name, cmd.Args...); e != nil {
+				if err = e; !isErrCode(e) {
+					err = redisCommandError(cmd.Name, e, ERR_EXEC_REDIS_COMMAND_FAILED.New(errors.Params{"cmd": cmd.Name, "args": cmd.Args, "err": e}))
+				}
+				return
+			}
+		}
+
+		if e := p.conn.Flush(); e != nil {
+			err = redisCommandError(commands[start].Name, e, ERR_EXEC_REDIS_COMMAND_FAILED.New(errors.Params{"cmd": commands[start].Name, "args": commands[start].Args, "err": e}))
+			return
+		}
+
+		for _, cmd := range commands[start:end] {
+			reply, e := p.conn.Receive()
+			if _, ok := e.(redigo.Error); ok {
+				if err = redisCommandError(cmd.Name, e, nil); err != nil {
+					return
+				}
+				reply = e
+			} else if e != nil {
+				if err = e; !isErrCode(e) {
+					err = redisCommandError(cmd.Name, e, ERR_EXEC_REDIS_COMMAND_FAILED.New(errors.Params{"cmd": cmd.Name, "args": cmd.Args, "err": e}))
+				}
+				return
+			}
+			replies = append(replies, reply)
+		}
+	}
+
+	return
+}
+
+// Do send the commands in batches and stop at the first error reply, the
+// batches after the failed one are not sent, but the commands after it in
+// the same batch were sent with it
+func (p *redisPipeline) Do(commands []redisCommand) (err error) {
+	for start := 0; start < len(commands); start += p.batchSize {
+		end := start + p.batchSize
+		if end > len(commands) {
+			end = len(commands)
+		}
+
+		var replies []interface{}
+		if replies, err = p.Run(commands[start:end]); err != nil {
+			return
+		}
+
+		for i, reply := range replies {
+			if e, ok := reply.(redigo.Error); ok {
+				err = commandError(commands[start+i], e)
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// commandError is the error of the failed write command, the key, field and
+// value of data are taken from the args of command
+func commandError(cmd redisCommand, e error) error {
+	arg := func(i int) interface{} {
+		if i < len(cmd.Args) {
+			return cmd.Args[i]
+		}
+		return ""
+	}
+
+	switch strings.ToUpper(cmd.Name) {
+	case "SET":
+		return ERR_SET_REDIS_DATA_ERROR.New(errors.Params{"key": arg(0), "value": arg(1), "err": e})
+	case "HSET":
+		return ERR_HSET_REDIS_DATA_ERROR.New(errors.Params{"key": arg(0), "field": arg(1), "value": arg(2), "err": e})
+	case "RPUSH":
+		return ERR_RPUSH_REDIS_DATA_ERROR.New(errors.Params{"key": arg(0), "value": arg(1), "err": e})
+	case "SADD":
+		return ERR_SADD_REDIS_DATA_ERROR.New(errors.Params{"key": arg(0), "value": arg(1), "err": e})
+	case "ZADD":
+		return ERR_ZADD_REDIS_DATA_ERROR.New(errors.Params{"key": arg(0), "score": arg(1), "member": arg(2), "err": e})
+	case "SREM", "ZREM":
+		return ERR_REMOVE_REDIS_MEMBER_FAILED.New(errors.Params{"key": arg(0), "member": arg(1), "err": e})
+	case "HDEL":
+		return ERR_HDEL_REDIS_FIELD_FAILED.New(errors.Params{"key": arg(0), "field": arg(1), "err": e})
+	case "EXPIRE":
+		return ERR_EXPIRE_REDIS_KEY_FAILED.New(errors.Params{"key": arg(0), "ttl": arg(1), "err": e})
+	case "DEL":
+		return ERR_DELETE_REDIS_KEY_FAILED.New(errors.Params{"key": arg(0), "err": e})
+	}
+
+	return ERR_EXEC_REDIS_COMMAND_FAILED.New(errors.Params{"cmd": cmd.Name, "args": cmd.Args, "err": e})
+}
+
+// Scan iterate the keys matched the pattern by SCAN, instead of KEYS which
+// will block the server, all the masters are scanned in cluster mode
+func (p *redisPipeline) Scan(match string) (keys []string, err error) {
+	keys = []string{}
+	keyExist := map[string]bool{}
+
+	conns := []redigo.Conn{p.conn}
+	if cluster, ok := p.conn.(*clusterConn); ok {
+		if conns, err = cluster.Masters(); err != nil {
+			return
+		}
+	}
+
+	for _, conn := range conns {
+		if err = scanKeys(conn, match, &keys, keyExist); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func scanKeys(conn redigo.Conn, match string, keys *[]string, keyExist map[string]bool) (err error) {
+	cursor := "0"
+	for {
+		values, e := redigo.Values(conn.Do("SCAN", cursor, "MATCH", match, "COUNT", scanCount()))
+		if e != nil {
+			err = redisCommandError("SCAN", e, ERR_GET_REDIS_KEYS_FAILED.New(errors.Params{"err": e}))
+			return
+		}
+
+		var items []string
+		if cursor, items, e = parseScanReply(values); e != nil {
+			err = ERR_GET_REDIS_KEYS_FAILED.New(errors.Params{"err": e})
+			return
+		}
+
+		for _, key := range items {
+			if !keyExist[key] {
+				keyExist[key] = true
+				*keys = append(*keys, key)
+			}
+		}
+
+		if cursor == "0" {
+			break
+		}
+	}
+
+	return
+}
+
+// Snapshot read the type and value of keys, by TYPE in batches first, and
+// then GET, LRANGE, HSCAN, SSCAN or ZSCAN in batches, the big hash, set and
+// zset will continue to scan with their cursors
+func (p *redisPipeline) Snapshot(keys []string, errorContinue bool) (snapshot map[string]redisValue, err error) {
+	snapshot = make(map[string]redisValue)
+
+	typeCommands := []redisCommand{}
+	for _, key := range keys {
+		typeCommands = append(typeCommands, redisCommand{Name: "TYPE", Args: []interface{}{key}})
+	}
+
+	var replies []interface{}
+	if replies, err = p.Run(typeCommands); err != nil {
+		return
+	}
+
+	scans := []*redisScan{}
+
+	for i, reply := range replies {
+		key := keys[i]
+
+		keyType, e := redigo.String(reply, nil)
+		if e != nil {
+			err = ERR_GET_REDIS_KEY_TYPE_FAILED.New(errors.Params{"key": key, "err": e})
+			return
+		}
+
+		snapshot[key] = redisValue{Type: keyType}
+
+		switch keyType {
+		case "string":
+			scans = append(scans, &redisScan{Key: key, Command: "GET"})
+		case "list":
+			scans = append(scans, &redisScan{Key: key, Command: "LRANGE"})
+		case "hash":
+			scans = append(scans, &redisScan{Key: key, Command: "HSCAN", Cursor: "0"})
+		case "set":
+			scans = append(scans, &redisScan{Key: key, Command: "SSCAN", Cursor: "0"})
+		case "zset":
+			scans = append(scans, &redisScan{Key: key, Command: "ZSCAN", Cursor: "0"})
+		}
+	}
+
+	if err = p.scanValues(scans); err != nil {
+		return
+	}
+
+	for _, scan := range scans {
+		value := snapshot[scan.Key]
+
+		e := scan.Err
+		if e == nil {
+			e = parseRedisValue(&value, scan.Items)
+		}
+
+		if e != nil {
+			if !errorContinue {
+				err = ERR_GET_REDIS_VALUE_ERROR.New(errors.Params{"key": scan.Key, "err": e})
+				return
+			}
+			value.Unknown = true
+		}
+
+		snapshot[scan.Key] = value
+	}
+
+	return
+}
+
+type redisScan struct {
+	Key     string
+	Command string
+	Cursor  string
+	Items   []string
+	Err     error
+}
+
+func (p *redisScan) redisCommand() redisCommand {
+	switch p.Command {
+	case "GET":
+		return redisCommand{Name: p.Command, Args: []interface{}{p.Key}}
+	case "LRANGE":
+		return redisCommand{Name: p.Command, Args: []interface{}{p.Key, 0, -1}}
+	}
+	return redisCommand{Name: p.Command, Args: []interface{}{p.Key, p.Cursor, "COUNT", scanCount()}}
+}
+
+// scanValues read the values in batches, round by round, until all the
+// cursors are finished
+func (p *redisPipeline) scanValues(scans []*redisScan) (err error) {
+	for len(scans) > 0 {
+		commands := []redisCommand{}
+		for _, scan := range scans {
+			commands = append(commands, scan.redisCommand())
+		}
+
+		var replies []interface{}
+		if replies, err = p.Run(commands); err != nil {
+			return
+		}
+
+		nextScans := []*redisScan{}
+		for i, reply := range replies {
+			scan := scans[i]
+
+			switch scan.Command {
+			case "GET":
+				{
+					var val string
+					if val, scan.Err = redigo.String(reply, nil); scan.Err == nil {
+						scan.Items = []string{val}
+					}
+				}
+			case "LRANGE":
+				{
+					scan.Items, scan.Err = redigo.Strings(reply, nil)
+				}
+			default:
+				{
+					var values []interface{}
+					if values, scan.Err = redigo.Values(reply, nil); scan.Err != nil {
+						continue
+					}
+
+					var items []string
+					if scan.Cursor, items, scan.Err = parseScanReply(values); scan.Err != nil {
+						continue
+					}
+
+					scan.Items = append(scan.Items, items...)
+
+					if scan.Cursor != "0" {
+						nextScans = append(nextScans, scan)
+					}
+				}
+			}
+		}
+
+		scans = nextScans
+	}
+
+	return
+}
+
+func parseScanReply(values []interface{}) (cursor string, items []string, err error) {
+	if len(values) != 2 {
+		err = errors.New("unexpected scan reply")
+		return
+	}
+
+	if cursor, err = redigo.String(values[0], nil); err != nil {
+		return
+	}
+
+	items, err = redigo.Strings(values[1], nil)
+	return
+}
+
+func isErrCode(e error) bool {
+	_, ok := e.(errors.ErrCode)
+	return ok
+}
+
+// redisCommandError return ERR_REDIS_ACL_NO_PERMISSION while the acl user
+// is not allowed to run the command, ERR_REDIS_CONNECTION_LOST while the
+// connection failed, otherwise return err
+func redisCommandError(cmd string, e error, err error) error {
+	if replyErr, ok := e.(redigo.Error); ok && strings.HasPrefix(string(replyErr), "NOPERM") {
+		return ERR_REDIS_ACL_NO_PERMISSION.New(errors.Params{"user": conf.Redis.Username, "cmd": cmd, "err": e})
+	} else if isConnectionError(e) {
+		return ERR_REDIS_CONNECTION_LOST.New(errors.Params{"cmd": cmd, "err": e})
+	}
+	return err
+}
+
+func scanCount() int {
+	if conf.Redis.ScanCount > 0 {
+		return conf.Redis.ScanCount
+	}
+	return _DEFAULT_SCAN_COUNT
+}
+
+func parseRedisValue(value *redisValue, items []string) (err error) {
+	switch value.Type {
+	case "string":
+		{
+			value.Value = items[0]
+		}
+	case "hash":
+		{
+			value.Fields = map[string]string{}
+			for i := 0; i+1 < len(items); i += 2 {
+				value.Fields[items[i]] = items[i+1]
+			}
+		}
+	case "list":
+		{
+			value.Value, err = serializeStrings(items)
+		}
+	case "set":
+		{
+			members := []string{}
+			memberExist := map[string]bool{}
+			for _, member := range items {
+				if !memberExist[member] {
+					memberExist[member] = true
+					members = append(members, member)
+				}
+			}
+
+			sort.Strings(members)
+
+			value.Value, err = serializeStrings(members)
+		}
+	case "zset":
+		{
+			scores := map[string]zsetScore{}
+			for i := 0; i+1 < len(items); i += 2 {
+				var score float64
+				if score, err = strconv.ParseFloat(items[i+1], 64); err != nil {
+					return
+				}
+				scores[items[i]] = zsetScore(score)
+			}
+
+			var data []byte
+			if data, err = json.Marshal(scores); err != nil {
+				return
+			}
+			value.Value = string(data)
+		}
+	}
+
+	return
+}
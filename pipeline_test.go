@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func dialTestRedis(t testing.TB, server *fakeRedisServer) redigo.Conn {
+	conn, e := redigo.Dial("tcp", server.Address())
+	if e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestPipelineCommandError(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	setRedisKey(server, "k", &memoryValue{Type: "string", Value: "v"})
+
+	pipe := newRedisPipeline(dialTestRedis(t, server), 0)
+
+	cases := []struct {
+		cmd  redisCommand
+		tmpl *errTemplate
+	}{
+		{redisCommand{Name: "HSET", Args: []interface{}{"k", "f", "v"}}, ERR_HSET_REDIS_DATA_ERROR},
+		{redisCommand{Name: "RPUSH", Args: []interface{}{"k", "v"}}, ERR_RPUSH_REDIS_DATA_ERROR},
+		{redisCommand{Name: "SADD", Args: []interface{}{"k", "v"}}, ERR_SADD_REDIS_DATA_ERROR},
+		{redisCommand{Name: "ZADD", Args: []interface{}{"k", 1, "v"}}, ERR_ZADD_REDIS_DATA_ERROR},
+		{redisCommand{Name: "SREM", Args: []interface{}{"k", "v"}}, ERR_REMOVE_REDIS_MEMBER_FAILED},
+		{redisCommand{Name: "ZREM", Args: []interface{}{"k", "v"}}, ERR_REMOVE_REDIS_MEMBER_FAILED},
+		{redisCommand{Name: "HDEL", Args: []interface{}{"k", "f"}}, ERR_HDEL_REDIS_FIELD_FAILED},
+	}
+
+	for _, c := range cases {
+		if e := pipe.Do([]redisCommand{c.cmd}); !c.tmpl.IsEqual(e) {
+			t.Errorf("Do(%s) = %v, want %v", c.cmd.Name, e, c.tmpl)
+		}
+	}
+
+	if e := pipe.Do([]redisCommand{{Name: "SET", Args: []interface{}{"k", "v2"}}}); e != nil {
+		t.Errorf("Do(SET) = %v, want nil", e)
+	}
+}
+
+func TestPipelineStopAtFailedBatch(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	setRedisKey(server, "k", &memoryValue{Type: "string", Value: "v"})
+
+	pipe := newRedisPipeline(dialTestRedis(t, server), 2)
+
+	commands := []redisCommand{
+		{Name: "SET", Args: []interface{}{"a", "1"}},
+		{Name: "HSET", Args: []interface{}{"k", "f", "v"}},
+		{Name: "SET", Args: []interface{}{"b", "2"}},
+		{Name: "SET", Args: []interface{}{"c", "3"}},
+	}
+
+	if e := pipe.Do(commands); !ERR_HSET_REDIS_DATA_ERROR.IsEqual(e) {
+		t.Fatalf("Do() = %v, want ERR_HSET_REDIS_DATA_ERROR", e)
+	}
+
+	if value := redisKey(server, "a"); value == nil || value.Value != "1" {
+		t.Errorf("a = %+v, want written before the failed command", value)
+	}
+
+	for _, key := range []string{"b", "c"} {
+		if value := redisKey(server, key); value != nil {
+			t.Errorf("%s = %+v, want the batch after the failed one not sent", key, value)
+		}
+	}
+}
+
+const _BENCHMARK_KEYS = 1000
+
+func newBenchmarkRedis(b *testing.B) (*fakeRedisServer, []string) {
+	server := startFakeRedis(b, "tcp", "127.0.0.1:0", nil)
+
+	keys := []string{}
+	for i := 0; i < _BENCHMARK_KEYS; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		keys = append(keys, key)
+		setRedisKey(server, key, &memoryValue{Type: "string", Value: "value"})
+	}
+
+	return server, keys
+}
+
+// BenchmarkReadPerKey read the keys by TYPE and GET, one round-trip per
+// command, as push did before the pipeline
+func BenchmarkReadPerKey(b *testing.B) {
+	server, keys := newBenchmarkRedis(b)
+	conn := dialTestRedis(b, server)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			if _, e := conn.Do("TYPE", key); e != nil {
+				b.Fatal(e)
+			}
+			if _, e := conn.Do("GET", key); e != nil {
+				b.Fatal(e)
+			}
+		}
+	}
+}
+
+func BenchmarkReadSnapshot(b *testing.B) {
+	server, keys := newBenchmarkRedis(b)
+	conn := dialTestRedis(b, server)
+
+	for _, batchSize := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("batch-%d", batchSize), func(b *testing.B) {
+			pipe := newRedisPipeline(conn, batchSize)
+			for i := 0; i < b.N; i++ {
+				if _, e := pipe.Snapshot(keys, false); e != nil {
+					b.Fatal(e)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkWritePerKey write the keys by SET, one round-trip per command
+func BenchmarkWritePerKey(b *testing.B) {
+	server, keys := newBenchmarkRedis(b)
+	conn := dialTestRedis(b, server)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			if _, e := conn.Do("SET", key, "value"); e != nil {
+				b.Fatal(e)
+			}
+		}
+	}
+}
+
+func BenchmarkWritePipeline(b *testing.B) {
+	server, keys := newBenchmarkRedis(b)
+	conn := dialTestRedis(b, server)
+
+	commands := []redisCommand{}
+	for _, key := range keys {
+		commands = append(commands, redisCommand{Name: "SET", Args: []interface{}{key, "value"}})
+	}
+
+	for _, batchSize := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("batch-%d", batchSize), func(b *testing.B) {
+			pipe := newRedisPipeline(conn, batchSize)
+			for i := 0; i < b.N; i++ {
+				if e := pipe.Do(commands); e != nil {
+					b.Fatal(e)
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineExecNoPermission(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	server.deny("HDEL")
+
+	pipe := newRedisPipeline(dialTestRedis(t, server), 0)
+
+	// the HDEL rejected while queued is reported, instead of EXEC
+	e := pipe.Exec([]redisCommand{
+		{Name: "SET", Args: []interface{}{"k", "v"}},
+		{Name: "HDEL", Args: []interface{}{"h", "f"}},
+	})
+	if !ERR_REDIS_ACL_NO_PERMISSION.IsEqual(e) || !strings.Contains(e.Error(), "run HDEL") {
+		t.Errorf("Exec() = %v, want ERR_REDIS_ACL_NO_PERMISSION of HDEL", e)
+	}
+
+	if value := redisKey(server, "k"); value != nil {
+		t.Errorf("k = %+v, want the transaction discarded", value)
+	}
+
+	if e := pipe.Exec([]redisCommand{{Name: "SET", Args: []interface{}{"k", "v2"}}}); e != nil {
+		t.Errorf("Exec() after the rejected transaction = %v, want nil", e)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// diffData compare the items of src to dst by key and field, added are
+// the items only in src, removed are the items only in dst, and changed
+// are the items of src which have a different value in dst. the item of
+// key changed its type is both removed and added, so the old data file of
+// the key is removed while the new one is written
+func diffData(src, dst map[string][]PushData) (added, removed, changed []PushData) {
+	added = []PushData{}
+	removed = []PushData{}
+	changed = []PushData{}
+
+	for _, key := range sortedDataKeys(src) {
+		for _, srcItem := range src[key] {
+			dstItem, exist := findDataItem(dst, srcItem.Key, srcItem.Field)
+			if !exist || dstItem.Type != srcItem.Type {
+				added = append(added, srcItem)
+			} else if srcItem.Value != dstItem.Value {
+				changed = append(changed, srcItem)
+			}
+		}
+	}
+
+	for _, key := range sortedDataKeys(dst) {
+		for _, dstItem := range dst[key] {
+			if srcItem, exist := findDataItem(src, dstItem.Key, dstItem.Field); !exist || srcItem.Type != dstItem.Type {
+				removed = append(removed, dstItem)
+			}
+		}
+	}
+
+	return
+}
+
+func findDataItem(data map[string][]PushData, key, field string) (PushData, bool) {
+	for _, item := range data[key] {
+		if item.Field == field {
+			return item, true
+		}
+	}
+	return PushData{}, false
+}
+
+func sortedDataKeys(data map[string][]PushData) []string {
+	keys := []string{}
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pushOperation is the operation of pushing data to redis
+func pushOperation(data PushData) outputOperation {
+	op := outputOperation{Key: data.Key, Field: data.Field, Type: data.Type, Value: data.Value}
+
+	switch data.Type {
+	case "string":
+		op.Op = "SET"
+		op.line = fmt.Sprintf("[SET]\t '%s' '%v'", data.Key, data.Value)
+	case "hash":
+		op.Op = "HSET"
+		op.line = fmt.Sprintf("[HSET]\t '%s' '%s' '%v'", data.Key, data.Field, data.Value)
+	case "list":
+		op.Op = "RPUSH"
+		op.line = fmt.Sprintf("[RPUSH]\t '%s' '%v'", data.Key, data.Value)
+	case "set":
+		op.Op = "SADD"
+		op.line = fmt.Sprintf("[SADD]\t '%s' '%v'", data.Key, data.Value)
+	case "zset":
+		op.Op = "ZADD"
+		op.line = fmt.Sprintf("[ZADD]\t '%s' '%v'", data.Key, data.Value)
+	}
+
+	return op
+}
+
+// commandOperation is the operation of the write command in the plan of
+// push, keyType is the type of key
+func commandOperation(cmd redisCommand, keyType string) outputOperation {
+	key := fmt.Sprint(cmd.Args[0])
+	op := outputOperation{Op: cmd.Name, Key: key, Type: keyType}
+
+	switch cmd.Name {
+	case "HSET":
+		op.Field, op.Value = fmt.Sprint(cmd.Args[1]), fmt.Sprint(cmd.Args[2])
+		op.line = fmt.Sprintf("[HSET]\t '%s' '%s' '%s'", key, op.Field, op.Value)
+	case "HDEL":
+		op.Field = fmt.Sprint(cmd.Args[1])
+		op.line = fmt.Sprintf("[HDEL]\t '%s' '%s'", key, op.Field)
+	case "ZADD":
+		op.Value = fmt.Sprint(cmd.Args[2])
+		op.line = fmt.Sprintf("[ZADD]\t '%s' '%v' '%s'", key, cmd.Args[1], op.Value)
+	case "DEL":
+		op.line = fmt.Sprintf("[DEL]\t '%s'", key)
+	default:
+		op.Value = fmt.Sprint(cmd.Args[1])
+		op.line = fmt.Sprintf("[%s]\t '%s' '%s'", cmd.Name, key, op.Value)
+	}
+
+	return op
+}
+
+// pruneOperation is the operation of deleting the key or field from redis
+func pruneOperation(data PushData) outputOperation {
+	if data.Field == "" {
+		return outputOperation{Op: "DEL", Key: data.Key, Type: data.Type, line: fmt.Sprintf("[DEL]\t '%s'", data.Key)}
+	}
+	return outputOperation{Op: "HDEL", Key: data.Key, Field: data.Field, Type: data.Type, line: fmt.Sprintf("[HDEL]\t '%s' '%s'", data.Key, data.Field)}
+}
+
+// pullOperations are the operations of local files while pulling
+func pullOperations(added, removed, changed []PushData) (ops []outputOperation) {
+	fnAppend := func(op string, items []PushData) {
+		for _, data := range items {
+			record := outputOperation{Op: op, Key: data.Key, Field: data.Field, Type: data.Type, Value: data.Value}
+			switch data.Type {
+			case "string":
+				record.File = "data"
+				record.line = fmt.Sprintf("[%s]\t 'data' '%s'", op, data.Key)
+			case "hash":
+				record.File = data.Key + "/data"
+				record.line = fmt.Sprintf("[%s]\t '%s/data' '%s'", op, data.Key, data.Field)
+			default:
+				record.File = data.Key + "/" + data.Type
+				record.line = fmt.Sprintf("[%s]\t '%s/%s'", op, data.Key, data.Type)
+			}
+			ops = append(ops, record)
+		}
+	}
+
+	fnAppend("ADD", added)
+	fnAppend("UPDATE", changed)
+	fnAppend("DELETE", removed)
+
+	return
+}
+
+// printPushPlan print the commands would be applied by push, pushed is the
+// count of data items, expired and pruned are the counts of commands
+func printPushPlan(ops []outputOperation, pushed, expired, pruned int, prune bool) {
+	printPlan(ops)
+
+	if prune {
+		printSummary(map[string]interface{}{"push": pushed, "expire": expired, "prune": pruned},
+			"push: %d, expire: %d, prune: %d\n", pushed, expired, pruned)
+	} else {
+		printSummary(map[string]interface{}{"push": pushed, "expire": expired},
+			"push: %d, expire: %d\n", pushed, expired)
+	}
+}
+
+func printPullPlan(redisData, localData map[string][]PushData) {
+	added, removed, changed := diffData(redisData, localData)
+
+	printPlan(pullOperations(added, removed, changed))
+
+	printSummary(map[string]interface{}{"update": len(changed), "delete": len(removed), "add": len(added)},
+		"update: %d, delete: %d, add: %d\n", len(changed), len(removed), len(added))
+}
+
+// printPlan print the operations grouped by key
+func printPlan(ops []outputOperation) {
+	plan := map[string][]outputOperation{}
+	for _, op := range ops {
+		plan[op.Key] = append(plan[op.Key], op)
+	}
+
+	keys := []string{}
+	for key := range plan {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !outputJSON {
+			fmt.Printf("%s:\n", key)
+		}
+
+		for _, op := range plan[key] {
+			op.line = "    " + op.line
+			printOperation(true, op)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/gogap/errors"
+	"golang.org/x/term"
+)
+
+const (
+	_ON_CONFLICT_PROMPT    = "prompt"
+	_ON_CONFLICT_SKIP      = "skip"
+	_ON_CONFLICT_OVERWRITE = "overwrite"
+	_ON_CONFLICT_FAIL      = "fail"
+)
+
+// prompter ask the user for confirmation line by line, it will not ask while
+// --yes is set, and fail while stdin is not a terminal
+type prompter struct {
+	reader      *bufio.Reader
+	interactive bool
+	yes         bool
+}
+
+func newPrompter(yes bool) *prompter {
+	return &prompter{
+		reader:      bufio.NewReader(os.Stdin),
+		interactive: isTerminal(os.Stdin),
+		yes:         yes,
+	}
+}
+
+// isTerminal check the file is a tty, the character devices such as
+// /dev/null are not terminal
+func isTerminal(file *os.File) bool {
+	return term.IsTerminal(int(file.Fd()))
+}
+
+// Confirm ask the question, the answer is no by default
+func (p *prompter) Confirm(question string) (yes bool, err error) {
+	if p.yes {
+		return true, nil
+	}
+
+	if !p.interactive {
+		err = ERR_CONFIRM_WITHOUT_TERMINAL.New(errors.Params{"question": question})
+		return
+	}
+
+	fmt.Fprintf(promptWriter(), "%s [y/N]: ", question)
+
+	line, e := p.reader.ReadString('\n')
+	if e != nil && e != io.EOF {
+		err = ERR_READ_USER_INPUT_ERROR.New()
+		return
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
+
+// Resolve decide whether overwrite the conflict of key by the policy
+func (p *prompter) Resolve(policy, key, question string) (overwrite bool, err error) {
+	switch policy {
+	case _ON_CONFLICT_OVERWRITE:
+		return true, nil
+	case _ON_CONFLICT_SKIP:
+		return false, nil
+	case _ON_CONFLICT_FAIL:
+		err = ERR_PUSH_CONFLICT.New(errors.Params{"key": key, "conflict": question})
+		return
+	}
+
+	return p.Confirm(question)
+}
+
+// conflictPolicy is the policy of --on-conflict, or by --no-overwrite,
+// --overwrite and --yes in order
+func conflictPolicy(c *cli.Context) (policy string, err error) {
+	policy = c.String("on-conflict")
+
+	switch policy {
+	case _ON_CONFLICT_PROMPT, _ON_CONFLICT_SKIP, _ON_CONFLICT_OVERWRITE, _ON_CONFLICT_FAIL:
+		return
+	case "":
+	default:
+		err = ERR_BAD_FLAG_VALUE.New(errors.Params{"flag": "on-conflict", "value": policy, "values": "prompt, skip, overwrite or fail"})
+		return
+	}
+
+	if c.Bool("no-overwrite") {
+		policy = _ON_CONFLICT_SKIP
+	} else if c.Bool("overwrite") || c.Bool("yes") {
+		policy = _ON_CONFLICT_OVERWRITE
+	} else {
+		policy = _ON_CONFLICT_PROMPT
+	}
+
+	return
+}
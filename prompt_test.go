@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConfirmWithoutTerminal(t *testing.T) {
+	devNull, e := os.Open(os.DevNull)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer devNull.Close()
+
+	if isTerminal(devNull) {
+		t.Errorf("isTerminal(%s) = true, want false", os.DevNull)
+	}
+
+	stdin := os.Stdin
+	os.Stdin = devNull
+	defer func() { os.Stdin = stdin }()
+
+	if _, e := newPrompter(false).Confirm("push?"); !ERR_CONFIRM_WITHOUT_TERMINAL.IsEqual(e) {
+		t.Errorf("Confirm() = %v, want ERR_CONFIRM_WITHOUT_TERMINAL", e)
+	}
+
+	if yes, e := newPrompter(true).Confirm("push?"); !yes || e != nil {
+		t.Errorf("Confirm() with --yes = %v, %v, want yes", yes, e)
+	}
+}
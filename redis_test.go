@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// TestRealRedis push and pull against the redis of REDIS_SYNC_TEST_ADDRESS by
+// RESP2 and RESP3, it is skipped while the address is not set. the db of
+// REDIS_SYNC_TEST_DB (default: 15) is flushed before and after the test
+func TestRealRedis(t *testing.T) {
+	address := os.Getenv("REDIS_SYNC_TEST_ADDRESS")
+	if address == "" {
+		t.Skip("REDIS_SYNC_TEST_ADDRESS is not set")
+	}
+
+	db := 15
+	if value := os.Getenv("REDIS_SYNC_TEST_DB"); value != "" {
+		var e error
+		if db, e = strconv.Atoi(value); e != nil {
+			t.Fatalf("bad REDIS_SYNC_TEST_DB: %v", e)
+		}
+	}
+
+	admin, e := redigo.Dial("tcp", address, redigo.DialDatabase(db), redigo.DialPassword(os.Getenv("REDIS_SYNC_TEST_AUTH")))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer admin.Close()
+
+	fnDo := func(cmd string, args ...interface{}) interface{} {
+		reply, e := admin.Do(cmd, args...)
+		if e != nil {
+			t.Fatalf("%s %v failed: %v", cmd, args, e)
+		}
+		return reply
+	}
+
+	for _, protocol := range []int{_PROTOCOL_RESP2, _PROTOCOL_RESP3} {
+		t.Run(fmt.Sprintf("resp%d", protocol), func(t *testing.T) {
+			fnDo("FLUSHDB")
+			defer fnDo("FLUSHDB")
+
+			initTestSyncDir(t, fmt.Sprintf(`{"redis": {"address": %q, "db": %d, "auth": %q, "protocol": %d}}`,
+				address, db, os.Getenv("REDIS_SYNC_TEST_AUTH"), protocol))
+			writeTestData(t)
+			writeTestFile(t, _TTL_FILE, `{"k1": 3600}`)
+			commitTestFiles(t)
+
+			if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+				t.Fatalf("push exit with %d", code)
+			}
+
+			if ttl, _ := redigo.Int64(fnDo("TTL", "k1"), nil); ttl <= 0 || ttl > 3600 {
+				t.Errorf("TTL k1 = %d, want 3600", ttl)
+			}
+
+			if members, _ := redigo.Strings(fnDo("ZRANGE", "z", 0, -1, "WITHSCORES"), nil); !reflect.DeepEqual(members, []string{"m", "1.5", "n", "2"}) {
+				t.Errorf("ZRANGE z = %v, want m: 1.5, n: 2", members)
+			}
+
+			assertInSync(t)
+
+			fnDo("SET", "k1", "changed")
+			fnDo("HDEL", "h", "f2")
+			fnDo("SADD", "s", "c")
+			fnDo("RPUSH", "n", "1")
+
+			if code := runCommand(t, "pull"); code != _EXIT_OK {
+				t.Fatalf("pull exit with %d", code)
+			}
+
+			assertInSync(t)
+			commitTestFiles(t)
+
+			fnDo("SET", "old", "v")
+			fnDo("HSET", "h", "f9", "v")
+
+			if code := runCommand(t, "push", "--atomic", "--watch", "--prune", "--yes"); code != _EXIT_OK {
+				t.Fatalf("push --atomic exit with %d", code)
+			}
+
+			if exist, _ := redigo.Bool(fnDo("EXISTS", "old"), nil); exist {
+				t.Errorf("old exists, want pruned")
+			}
+
+			assertInSync(t)
+		})
+	}
+}
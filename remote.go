@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/gogap/errors"
+	"github.com/nu7hatch/gouuid"
+)
+
+const (
+	_REMOTE_TOKENS_DIR = ".redis_sync/tokens"
+)
+
+var (
+	currentRemote = ""
+
+	remoteNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// validateRemoteName check the name of remote, it is the file name of the
+// token in .redis_sync/tokens, so it could not be a path
+func validateRemoteName(name string) error {
+	if !remoteNameRegexp.MatchString(name) || name == "." || name == ".." {
+		return ERR_BAD_REMOTE_NAME.New(errors.Params{"name": name})
+	}
+	return nil
+}
+
+// syncTokenFile is the local token file of current remote, the default
+// redis of config use the token file created by init
+func syncTokenFile() string {
+	if currentRemote == "" {
+		return ".redis_sync/token"
+	}
+	return _REMOTE_TOKENS_DIR + "/" + currentRemote
+}
+
+func selectRemote(name string) (err error) {
+	if name == "" {
+		return
+	}
+
+	remoteConf, exist := conf.Remotes[name]
+	if !exist {
+		err = ERR_REMOTE_NOT_EXIST.New(errors.Params{"name": name})
+		return
+	}
+
+	conf.Redis = remoteConf
+	currentRemote = name
+
+	return
+}
+
+func cmdRemoteList(c *cli.Context) {
+	var err error
+
+	defer func() {
+		if err != nil {
+			exitError(err)
+		}
+	}()
+
+	if !checkIsSyncDir() {
+		err = ERR_THE_CWD_IS_NOT_SYNC_DIR.New()
+		return
+	}
+
+	if err = initalConfig(c.String("config")); err != nil {
+		return
+	}
+
+	names := []string{}
+	for name := range conf.Remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		remoteConf := conf.Remotes[name]
+
+		address := ""
+		if remoteConf.Sentinel != nil {
+			address = fmt.Sprintf("sentinel:%s/%d", remoteConf.Sentinel.MasterName, remoteConf.Db)
+		} else if remoteConf.Cluster != nil {
+			address = "cluster:" + strings.Join(remoteConf.Cluster.Addresses, ",")
+		} else {
+			address = fmt.Sprintf("%s/%d", remoteConf.Address, remoteConf.Db)
+		}
+
+		printOperation(true, outputOperation{Op: "REMOTE", Key: name, Value: address, line: name + "\t" + address})
+	}
+}
+
+func cmdRemoteAdd(c *cli.Context) {
+	var err error
+
+	defer func() {
+		if err != nil {
+			exitError(err)
+		}
+	}()
+
+	if !checkIsSyncDir() {
+		err = ERR_THE_CWD_IS_NOT_SYNC_DIR.New()
+		return
+	}
+
+	configFile := c.String("config")
+
+	if err = initalConfig(configFile); err != nil {
+		return
+	}
+
+	name := c.Args().First()
+	if name == "" {
+		err = ERR_CONFIG_VALUE_MUST_INPUT.New(errors.Params{"configName": "remote name"})
+		return
+	}
+
+	if err = validateRemoteName(name); err != nil {
+		return
+	}
+
+	if _, exist := conf.Remotes[name]; exist {
+		err = ERR_REMOTE_ALREADY_EXIST.New(errors.Params{"name": name})
+		return
+	}
+
+	remoteConf := redisConfig{
+		Address:  c.String("address"),
+		Db:       c.Int("db"),
+		Username: c.String("username"),
+		Auth:     c.String("auth"),
+		AuthEnv:  c.String("auth-env"),
+		AuthFile: c.String("auth-file"),
+	}
+
+	if remoteConf.Address == "" {
+		err = ERR_CONFIG_VALUE_MUST_INPUT.New(errors.Params{"configName": "address"})
+		return
+	}
+
+	token := c.String("token")
+
+	if token == "" {
+		tokenUUID, _ := uuid.NewV4()
+		token = strings.Replace(tokenUUID.String(), "-", "", -1)
+	}
+
+	currentRemote = name
+
+	if e := os.MkdirAll(_REMOTE_TOKENS_DIR, 0766); e != nil {
+		err = ERR_WRITE_SYNC_TOKEN_FAILED.New(errors.Params{"err": e})
+		return
+	}
+
+	if e := ioutil.WriteFile(syncTokenFile(), []byte(token), 0644); e != nil {
+		err = ERR_WRITE_SYNC_TOKEN_FAILED.New(errors.Params{"err": e})
+		return
+	}
+
+	err = saveConfigRemote(configFile, name, &remoteConf)
+}
+
+func cmdRemoteRemove(c *cli.Context) {
+	var err error
+
+	defer func() {
+		if err != nil {
+			exitError(err)
+		}
+	}()
+
+	if !checkIsSyncDir() {
+		err = ERR_THE_CWD_IS_NOT_SYNC_DIR.New()
+		return
+	}
+
+	configFile := c.String("config")
+
+	if err = initalConfig(configFile); err != nil {
+		return
+	}
+
+	name := c.Args().First()
+
+	if _, exist := conf.Remotes[name]; !exist {
+		err = ERR_REMOTE_NOT_EXIST.New(errors.Params{"name": name})
+		return
+	}
+
+	currentRemote = name
+
+	if e := os.Remove(syncTokenFile()); e != nil && !os.IsNotExist(e) {
+		err = ERR_WRITE_SYNC_TOKEN_FAILED.New(errors.Params{"err": e})
+		return
+	}
+
+	err = saveConfigRemote(configFile, name, nil)
+}
+
+// saveConfigRemote add or remove (while remoteConf is nil) the remote of the
+// config file, the config file is read again without resolving, so the
+// ${ENV_VAR} and the secrets of auth_env or auth_file are not written to it
+func saveConfigRemote(configFile, name string, remoteConf *redisConfig) (err error) {
+	if configFile == "" {
+		configFile = "./redis_sync.conf"
+	}
+
+	var data []byte
+	if data, err = ioutil.ReadFile(configFile); err != nil {
+		err = ERR_LOAD_CONFIG_FAILED.New(errors.Params{"fileName": configFile, "err": err})
+		return
+	}
+
+	rawConf := syncConfig{}
+	if err = json.Unmarshal(data, &rawConf); err != nil {
+		err = ERR_PARSE_CONFIG_FAILED.New(errors.Params{"fileName": configFile, "err": err})
+		return
+	}
+
+	if remoteConf == nil {
+		delete(rawConf.Remotes, name)
+	} else {
+		if rawConf.Remotes == nil {
+			rawConf.Remotes = make(map[string]redisConfig)
+		}
+		rawConf.Remotes[name] = *remoteConf
+	}
+
+	strConf := ""
+
+	if strConf, err = rawConf.Serialize(); err != nil {
+		return
+	}
+
+	if e := ioutil.WriteFile(configFile, []byte(strConf), 0644); e != nil {
+		err = ERR_WRITE_INIT_CONF_ERROR.New(errors.Params{"err": e})
+		return
+	}
+
+	return
+}
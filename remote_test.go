@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoteName(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	dir := newTestSyncDir(t, server)
+
+	for _, name := range []string{"../../x", "a/b", ".", "..", "a b"} {
+		if code := runCommand(t, "remote", "add", name, "--address", server.Address()); code != _EXIT_CONFIG {
+			t.Errorf("remote add %q exit with %d, want %d", name, code, _EXIT_CONFIG)
+		}
+	}
+
+	if _, e := os.Stat(filepath.Join(dir, "x")); !os.IsNotExist(e) {
+		t.Errorf("the token file is written out of %s", _REMOTE_TOKENS_DIR)
+	}
+
+	if code := runCommand(t, "remote", "add", "staging-1.a_b", "--address", server.Address()); code != _EXIT_OK {
+		t.Fatalf("remote add exit with %d", code)
+	}
+
+	if _, e := os.Stat(filepath.Join(_REMOTE_TOKENS_DIR, "staging-1.a_b")); e != nil {
+		t.Errorf("the token file of remote is not written: %v", e)
+	}
+
+	// the names in config are checked too
+	writeTestFile(t, "redis_sync.conf", `{"redis": {"address": "`+server.Address()+`"}, "remotes": {"../x": {"address": "`+server.Address()+`"}}}`)
+	if code := runCommand(t, "push", "--remote", "../x", "--yes"); code != _EXIT_CONFIG {
+		t.Errorf("push to the remote of bad name exit with %d, want %d", code, _EXIT_CONFIG)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogap/errors"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	_PROTOCOL_RESP2 = 2
+	_PROTOCOL_RESP3 = 3
+
+	_DEFAULT_AUTH_USERNAME = "default"
+)
+
+// resp3DialFunc dial the redis node and switch the connection to RESP3 by
+// HELLO 3, the auth is sent by HELLO too, the tls handshake is done here
+// before HELLO, so redigo should not do it again
+func resp3DialFunc(tlsConf *tls.Config) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (netConn net.Conn, err error) {
+		dialer := net.Dialer{
+			Timeout:   secondsOrDefault(conf.Redis.ConnectTimeout, _DEFAULT_CONNECT_TIMEOUT),
+			KeepAlive: 5 * time.Minute,
+		}
+
+		if netConn, err = dialer.DialContext(ctx, network, address); err != nil {
+			return
+		}
+
+		if tlsConf != nil {
+			nodeTLSConf := tlsConf.Clone()
+			if nodeTLSConf.ServerName == "" && network != "unix" {
+				nodeTLSConf.ServerName, _, _ = net.SplitHostPort(address)
+			}
+
+			tlsConn := tls.Client(netConn, nodeTLSConf)
+			if err = tlsConn.HandshakeContext(ctx); err != nil {
+				netConn.Close()
+				return
+			}
+			netConn = tlsConn
+		}
+
+		netConn = newResp3Conn(netConn)
+
+		args := []interface{}{_PROTOCOL_RESP3}
+		if conf.Redis.Auth != "" {
+			username := conf.Redis.Username
+			if username == "" {
+				username = _DEFAULT_AUTH_USERNAME
+			}
+			args = append(args, "AUTH", username, conf.Redis.Auth)
+		}
+
+		// the server replies nothing before the next command, so the reader
+		// of this conn will not take the bytes of redigo's
+		helloConn := redigo.NewConn(netConn,
+			secondsOrDefault(conf.Redis.ReadTimeout, _DEFAULT_READ_TIMEOUT),
+			secondsOrDefault(conf.Redis.WriteTimeout, _DEFAULT_WRITE_TIMEOUT))
+
+		if _, err = helloConn.Do("HELLO", args...); err != nil {
+			netConn.Close()
+			return
+		}
+
+		return
+	}
+}
+
+// resp3Conn translate the replies of RESP3 to RESP2 for redigo, which only
+// knows RESP2: the map is the array of keys and values, the set and push are
+// arrays, the null is the null bulk string, the boolean is 1 or 0, the double,
+// big number and verbatim string are bulk strings, and the attributes are
+// dropped. the commands are sent as they are
+type resp3Conn struct {
+	net.Conn
+
+	reader *bufio.Reader
+	buffer bytes.Buffer
+}
+
+func newResp3Conn(conn net.Conn) *resp3Conn {
+	return &resp3Conn{Conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (p *resp3Conn) Read(b []byte) (n int, err error) {
+	for p.buffer.Len() == 0 {
+		if err = p.translate(&p.buffer); err != nil {
+			p.buffer.Reset()
+			return
+		}
+	}
+
+	return p.buffer.Read(b)
+}
+
+// translate read one reply of RESP3 and write it as RESP2, the push messages
+// are not replies of the commands, they are dropped
+func (p *resp3Conn) translate(w *bytes.Buffer) (err error) {
+	var line string
+	if line, err = p.readLine(); err != nil {
+		return
+	}
+
+	prefix, body := line[0], line[1:]
+
+	switch prefix {
+	case '+', '-', ':':
+		fmt.Fprintf(w, "%s\r\n", line)
+	case '$':
+		var data []byte
+		if data, err = p.readBlob(body); err != nil {
+			return
+		}
+
+		if data == nil {
+			w.WriteString("$-1\r\n")
+		} else {
+			writeBulkString(w, data)
+		}
+	case '*', '%', '~':
+		var count int
+		if count, err = strconv.Atoi(body); err != nil {
+			return resp3ProtocolError(line)
+		} else if count < 0 {
+			w.WriteString("*-1\r\n")
+			return
+		}
+
+		if prefix == '%' {
+			count *= 2
+		}
+
+		fmt.Fprintf(w, "*%d\r\n", count)
+		for i := 0; i < count; i++ {
+			if err = p.translate(w); err != nil {
+				return
+			}
+		}
+	case '_':
+		w.WriteString("$-1\r\n")
+	case '#':
+		switch body {
+		case "t":
+			w.WriteString(":1\r\n")
+		case "f":
+			w.WriteString(":0\r\n")
+		default:
+			return resp3ProtocolError(line)
+		}
+	case ',', '(':
+		writeBulkString(w, []byte(body))
+	case '!':
+		var data []byte
+		if data, err = p.readBlob(body); err != nil {
+			return
+		}
+		fmt.Fprintf(w, "-%s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(string(data)))
+	case '=':
+		var data []byte
+		if data, err = p.readBlob(body); err != nil {
+			return
+		} else if len(data) < 4 {
+			return resp3ProtocolError(line)
+		}
+
+		// the data starts with the format of 3 bytes and a colon, such as txt:
+		writeBulkString(w, data[4:])
+	case '|', '>':
+		var count int
+		if count, err = strconv.Atoi(body); err != nil {
+			return resp3ProtocolError(line)
+		}
+
+		if prefix == '|' {
+			count *= 2
+		}
+
+		discard := bytes.Buffer{}
+		for i := 0; i < count; i++ {
+			if err = p.translate(&discard); err != nil {
+				return
+			}
+		}
+
+		// the attributes are followed by the reply they described
+		if prefix == '|' {
+			return p.translate(w)
+		}
+	default:
+		return resp3ProtocolError(line)
+	}
+
+	return
+}
+
+func (p *resp3Conn) readLine() (line string, err error) {
+	if line, err = p.reader.ReadString('\n'); err != nil {
+		return
+	}
+
+	if line = strings.TrimSuffix(line, "\r\n"); line == "" {
+		err = resp3ProtocolError(line)
+	}
+	return
+}
+
+// readBlob read the data of blob string, it is nil while the length is -1
+func (p *resp3Conn) readBlob(size string) (data []byte, err error) {
+	var n int
+	if n, err = strconv.Atoi(size); err != nil {
+		return nil, resp3ProtocolError(size)
+	} else if n < 0 {
+		return
+	}
+
+	data = make([]byte, n+2)
+	if _, err = io.ReadFull(p.reader, data); err != nil {
+		return
+	}
+	return data[:n], nil
+}
+
+func writeBulkString(w *bytes.Buffer, data []byte) {
+	fmt.Fprintf(w, "$%d\r\n", len(data))
+	w.Write(data)
+	w.WriteString("\r\n")
+}
+
+func resp3ProtocolError(line string) error {
+	return errors.New(fmt.Sprintf("bad RESP3 reply: %q", line))
+}
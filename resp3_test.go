@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func TestResp3Conn(t *testing.T) {
+	cases := []struct {
+		reply string
+		want  interface{}
+		err   string
+	}{
+		{"%2\r\n+a\r\n$1\r\n1\r\n+b\r\n#t\r\n", []interface{}{"a", []byte("1"), "b", int64(1)}, ""},
+		{"~2\r\n$1\r\nx\r\n$1\r\ny\r\n", []interface{}{[]byte("x"), []byte("y")}, ""},
+		{"*2\r\n_\r\n%0\r\n", []interface{}{nil, []interface{}{}}, ""},
+		{"_\r\n", nil, ""},
+		{"$-1\r\n", nil, ""},
+		{"$0\r\n\r\n", []byte{}, ""},
+		{"#f\r\n", int64(0), ""},
+		{",3.14\r\n", []byte("3.14"), ""},
+		{"(3492890328409238509324850943850943825024385\r\n", []byte("3492890328409238509324850943850943825024385"), ""},
+		{"=15\r\ntxt:Some string\r\n", []byte("Some string"), ""},
+		{"!21\r\nSYNTAX invalid syntax\r\n", nil, "SYNTAX invalid syntax"},
+		{"-ERR unknown command\r\n", nil, "ERR unknown command"},
+		{"|1\r\n+key-popularity\r\n%1\r\n$1\r\na\r\n,0.19\r\n:42\r\n", int64(42), ""},
+		{">2\r\n+invalidate\r\n*1\r\n$1\r\nk\r\n+OK\r\n", "OK", ""},
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		for _, c := range cases {
+			server.Write([]byte(c.reply))
+		}
+	}()
+
+	conn := redigo.NewConn(newResp3Conn(client), 0, 0)
+	for _, c := range cases {
+		reply, e := conn.Receive()
+		if c.err != "" {
+			if replyErr, ok := e.(redigo.Error); !ok || string(replyErr) != c.err {
+				t.Errorf("reply of %q = %v, %v, want error %s", c.reply, reply, e, c.err)
+			}
+			continue
+		}
+
+		if e != nil || !reflect.DeepEqual(reply, c.want) {
+			t.Errorf("reply of %q = %#v, %v, want %#v", c.reply, reply, e, c.want)
+		}
+	}
+}
+
+func TestPushPullRESP3(t *testing.T) {
+	server := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	server.password = "secret"
+
+	newTestSyncDir(t, server)
+	writeTestFile(t, "redis_sync.conf", `{"redis": {"address": "`+server.Address()+`", "auth": "secret", "protocol": 3}}`)
+	writeTestData(t)
+	server.Commands()
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	if commands := server.Commands(); len(commands) == 0 || commands[0] != "HELLO" {
+		t.Errorf("commands = %v, want HELLO first", commands)
+	}
+
+	setRedisKey(server, "k1", &memoryValue{Type: "string", Value: "changed"})
+	setRedisKey(server, "h", &memoryValue{Type: "hash", Fields: map[string]string{"f1": "a", "f3": "c"}})
+
+	if code := runCommand(t, "pull"); code != _EXIT_OK {
+		t.Fatalf("pull exit with %d", code)
+	}
+
+	assertInSync(t)
+
+	resetGlobals()
+	conf.Redis = redisConfig{Address: server.Address(), Auth: "wrong", Protocol: _PROTOCOL_RESP3, MaxRetries: -1}
+	if e := checkRedisHealth(); !ERR_DAIL_REDIS_FAILED.IsEqual(e) {
+		t.Errorf("ping redis with the wrong auth = %v, want ERR_DAIL_REDIS_FAILED", e)
+	}
+}
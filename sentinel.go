@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gogap/errors"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	_SENTINEL_DIAL_RETRIES = 3
+	_SENTINEL_RETRY_WAIT   = time.Second
+)
+
+type sentinelConfig struct {
+	Addresses  []string `json:"addresses"`
+	MasterName string   `json:"master_name"`
+	Username   string   `json:"username,omitempty"`
+	Auth       string   `json:"auth,omitempty"`
+}
+
+var (
+	// sentinelMaster is the last resolved master address, it is used to
+	// report the failover during the run
+	sentinelMaster = ""
+)
+
+// resolveMaster ask the sentinels one by one for the current master address
+func (p *sentinelConfig) resolveMaster() (address string, err error) {
+	var lastErr error
+	for _, sentinelAddress := range p.Addresses {
+		if address, lastErr = p.queryMaster(sentinelAddress); lastErr == nil {
+			return
+		}
+	}
+
+	err = ERR_RESOLVE_SENTINEL_MASTER_FAILED.New(errors.Params{"name": p.MasterName, "err": lastErr})
+	return
+}
+
+func (p *sentinelConfig) queryMaster(sentinelAddress string) (address string, err error) {
+	var options []redigo.DialOption
+	if options, err = p.dialOptions(); err != nil {
+		return
+	}
+
+	var conn redigo.Conn
+	if conn, err = dialRedisNode("tcp", sentinelAddress, options); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var values []string
+	if values, err = redigo.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", p.MasterName)); err != nil {
+		return
+	}
+
+	if len(values) != 2 {
+		err = errors.New("unexpected sentinel reply")
+		return
+	}
+
+	address = net.JoinHostPort(values[0], values[1])
+	return
+}
+
+// dialOptions are the options of the sentinels, they use the tls of redis,
+// and the username and auth of redis while the ones of sentinel are not set
+func (p *sentinelConfig) dialOptions() (options []redigo.DialOption, err error) {
+	username, auth := p.Username, p.Auth
+	if username == "" && auth == "" {
+		username, auth = conf.Redis.Username, conf.Redis.Auth
+	}
+
+	options = append(redisTimeoutOptions(),
+		redigo.DialUsername(username),
+		redigo.DialPassword(auth),
+	)
+
+	var tlsConf *tls.Config
+	if tlsConf, err = conf.Redis.TLSConfig(); err != nil {
+		return
+	} else if tlsConf != nil {
+		options = append(options, redigo.DialUseTLS(true), redigo.DialTLSConfig(tlsConf))
+	}
+
+	return
+}
+
+// dialSentinelMaster resolve the master and dial it, every dial resolve the
+// master again, so the later steps of push or pull will follow the new master
+// after failover, while the failover is in progress the resolved node may not
+// be master yet, it will retry a few times
+func dialSentinelMaster(options []redigo.DialOption) (conn redigo.Conn, err error) {
+	sentinel := conf.Redis.Sentinel
+
+	for i := 0; ; i++ {
+		var address string
+		if address, err = sentinel.resolveMaster(); err != nil {
+			return
+		}
+
+		if sentinelMaster != "" && sentinelMaster != address {
+			printOperation(true, outputOperation{Op: "SENTINEL", Key: sentinel.MasterName, Value: address,
+				line: fmt.Sprintf("[SENTINEL]\t the master of '%s' changed from %s to %s", sentinel.MasterName, sentinelMaster, address)})
+		}
+		sentinelMaster = address
+
+		if conn, err = dialRedisNode("tcp", address, options); err == nil {
+			if isRedisMaster(conn) {
+				return
+			}
+
+			conn.Close()
+			conn = nil
+			err = ERR_SENTINEL_MASTER_NOT_READY.New(errors.Params{"name": sentinel.MasterName, "address": address})
+		}
+
+		if i >= _SENTINEL_DIAL_RETRIES {
+			return
+		}
+
+		time.Sleep(_SENTINEL_RETRY_WAIT)
+	}
+}
+
+func isRedisMaster(conn redigo.Conn) bool {
+	values, e := redigo.Values(conn.Do("ROLE"))
+	if _, ok := e.(redigo.Error); ok {
+		// the user may have no permission of ROLE, trust the sentinel
+		return true
+	} else if e != nil || len(values) == 0 {
+		return false
+	}
+
+	role, _ := redigo.String(values[0], nil)
+	return role == "master"
+}
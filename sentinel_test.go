@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+)
+
+func TestSentinelResolveMaster(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, "redis_sync ca", nil, true)
+	serverCert := newTestCert(t, "redis", ca, false)
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+
+	serverTLS := &tls.Config{Certificates: []tls.Certificate{serverCert.tlsCertificate()}}
+
+	master := startFakeRedis(t, "tcp", "127.0.0.1:0", serverTLS)
+	master.password = "secret"
+
+	sentinel := startFakeRedis(t, "tcp", "127.0.0.1:0", serverTLS)
+	sentinel.password = "secret"
+	sentinel.setSentinelMaster("mymaster", master.Address())
+
+	// the first sentinel is down, the next one is asked
+	listener, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	downAddress := listener.Addr().String()
+	listener.Close()
+
+	resetGlobals()
+	defer resetGlobals()
+
+	// the sentinel uses the tls, username and auth of redis
+	conf.Redis = redisConfig{Auth: "secret", MaxRetries: -1, TLS: &tlsConfig{Enable: true, CAFile: caFile},
+		Sentinel: &sentinelConfig{Addresses: []string{downAddress, sentinel.Address()}, MasterName: "mymaster"}}
+
+	if address, e := conf.Redis.Sentinel.resolveMaster(); e != nil || address != master.Address() {
+		t.Fatalf("resolveMaster() = %s, %v, want %s", address, e, master.Address())
+	}
+
+	if e := pingRedis(); e != nil {
+		t.Fatalf("ping the master of sentinel failed: %v", e)
+	}
+
+	conf.Redis.Sentinel.Auth = "wrong"
+	if _, e := conf.Redis.Sentinel.resolveMaster(); !ERR_RESOLVE_SENTINEL_MASTER_FAILED.IsEqual(e) {
+		t.Errorf("resolveMaster() with the wrong auth of sentinel = %v, want ERR_RESOLVE_SENTINEL_MASTER_FAILED", e)
+	}
+
+	conf.Redis.Sentinel.Auth = ""
+	conf.Redis.Sentinel.MasterName = "other"
+	if _, e := conf.Redis.Sentinel.resolveMaster(); !ERR_RESOLVE_SENTINEL_MASTER_FAILED.IsEqual(e) {
+		t.Errorf("resolveMaster() of unknown master = %v, want ERR_RESOLVE_SENTINEL_MASTER_FAILED", e)
+	}
+}
+
+func TestSentinelFailover(t *testing.T) {
+	master1 := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	master2 := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+
+	sentinel := startFakeRedis(t, "tcp", "127.0.0.1:0", nil)
+	sentinel.setSentinelMaster("mymaster", master1.Address())
+
+	initTestSyncDir(t, `{"redis": {"sentinel": {"addresses": ["`+sentinel.Address()+`"], "master_name": "mymaster"}}}`)
+	writeTestData(t)
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push exit with %d", code)
+	}
+
+	if value := redisKey(master1, "k1"); value == nil {
+		t.Fatalf("k1 is not pushed to the master")
+	}
+
+	// failover, the next run follows the new master
+	master1.setRole("slave")
+	sentinel.setSentinelMaster("mymaster", master2.Address())
+
+	if code := runCommand(t, "push", "--yes"); code != _EXIT_OK {
+		t.Fatalf("push after failover exit with %d", code)
+	}
+
+	if value := redisKey(master2, "k1"); value == nil {
+		t.Errorf("k1 is not pushed to the new master")
+	}
+
+	resetGlobals()
+	if e := initalConfig(""); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := pingRedis(); e != nil {
+		t.Fatal(e)
+	}
+
+	// failover during the run, the idle connection of the old master is
+	// dropped by ROLE while it is borrowed
+	master2.setRole("slave")
+	master1.setRole("master")
+	sentinel.setSentinelMaster("mymaster", master1.Address())
+	master2.Commands()
+
+	conn, e := dialRedis()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if _, e := conn.Do("SET", "b", "2"); e != nil {
+		t.Fatal(e)
+	}
+	conn.Close()
+
+	role := false
+	for _, cmd := range master2.Commands() {
+		role = role || cmd == "ROLE"
+	}
+
+	if !role {
+		t.Errorf("the idle connection of the old master is not checked by ROLE")
+	}
+	if value := redisKey(master1, "b"); value == nil || sentinelMaster != master1.Address() {
+		t.Errorf("b = %+v, master = %s, want written to the new master %s", value, sentinelMaster, master1.Address())
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// fakeRedisServer serve the memory redis by RESP, so the tests go through
+// the real connections of redigo, including the pool, tls and unix socket
+type fakeRedisServer struct {
+	listener net.Listener
+	store    *memoryStore
+	network  string
+
+	// commands are the names of commands received, in order
+	commands []string
+
+	// password is checked by AUTH and HELLO while it is set
+	password string
+
+	// cluster is the fake cluster of the node, the keys of the slots served
+	// by other nodes are redirected by MOVED or ASK
+	cluster *fakeCluster
+
+	// masters are the master addresses of SENTINEL get-master-addr-by-name,
+	// and role is the reply of ROLE, it is master while not set
+	masters map[string]string
+	role    string
+
+	// before is called with the args of every command before it runs, so
+	// the tests could change the keys between the commands of redis sync
+	before func(args []string)
+}
+
+func startFakeRedis(t testing.TB, network, address string, tlsConf *tls.Config) *fakeRedisServer {
+	listener, e := net.Listen(network, address)
+	if e != nil {
+		t.Fatalf("listen %s %s failed: %v", network, address, e)
+	}
+
+	if tlsConf != nil {
+		listener = tls.NewListener(listener, tlsConf)
+	}
+
+	server := &fakeRedisServer{listener: listener, store: newMemoryStore(), network: network}
+	t.Cleanup(func() { listener.Close() })
+
+	go server.serve()
+	return server
+}
+
+// Address is the address of server in config, the unix socket is
+// unix:///path/to/redis.sock
+func (p *fakeRedisServer) Address() string {
+	if p.network == "unix" {
+		return "unix://" + p.listener.Addr().String()
+	}
+	return p.listener.Addr().String()
+}
+
+// Commands return the names of commands received, and clear them
+func (p *fakeRedisServer) Commands() []string {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	commands := p.commands
+	p.commands = nil
+	return commands
+}
+
+func (p *fakeRedisServer) serve() {
+	for {
+		netConn, e := p.listener.Accept()
+		if e != nil {
+			return
+		}
+		go p.handle(netConn)
+	}
+}
+
+func (p *fakeRedisServer) handle(netConn net.Conn) {
+	defer netConn.Close()
+
+	reader := bufio.NewReader(netConn)
+	writer := bufio.NewWriter(netConn)
+	conn := dialMemoryRedis(p.store, 0)
+	resp3 := false
+	asking := false
+
+	p.store.Lock()
+	cluster := p.cluster
+	p.store.Unlock()
+
+	for {
+		args, e := readRESPCommand(reader)
+		if e != nil {
+			return
+		}
+
+		p.store.Lock()
+		p.commands = append(p.commands, strings.ToUpper(args[0]))
+		before := p.before
+		p.store.Unlock()
+
+		if before != nil {
+			before(args)
+		}
+
+		asked := asking
+		asking = false
+
+		var reply interface{}
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			reply = p.auth(args[len(args)-1])
+		case "HELLO":
+			reply = redigo.Error("NOPROTO unsupported protocol version")
+			if len(args) > 1 && (args[1] == "2" || args[1] == "3") {
+				if len(args) == 5 && strings.ToUpper(args[2]) == "AUTH" {
+					reply = p.auth(args[4])
+				} else {
+					reply = p.auth("")
+				}
+
+				if _, ok := reply.(redigo.Error); !ok {
+					resp3 = args[1] == "3"
+					reply = helloReply{proto: args[1]}
+				}
+			}
+		case "SELECT":
+			db, _ := strconv.Atoi(args[1])
+			p.store.Lock()
+			conn = dialMemoryRedis(p.store, db)
+			p.store.Unlock()
+			reply = "OK"
+		case "CLUSTER":
+			reply = redigo.Error("ERR This instance has cluster support disabled")
+			if cluster != nil && len(args) > 1 && strings.ToUpper(args[1]) == "SLOTS" {
+				reply = cluster.slotsReply()
+			}
+		case "ASKING":
+			asking = true
+			reply = "OK"
+		case "SENTINEL":
+			reply = redigo.Error("ERR unknown command 'SENTINEL'")
+			if len(args) == 3 && strings.ToLower(args[1]) == "get-master-addr-by-name" {
+				reply = p.sentinelReply(args[2])
+			}
+		case "ROLE":
+			p.store.Lock()
+			role := p.role
+			p.store.Unlock()
+
+			if role == "" {
+				role = "master"
+			}
+			reply = []interface{}{[]byte(role), int64(0), []interface{}{}}
+		default:
+			if cluster != nil {
+				if reply = cluster.redirect(p, args, asked); reply != nil {
+					break
+				}
+			}
+
+			cmdArgs := []interface{}{}
+			for _, arg := range args[1:] {
+				cmdArgs = append(cmdArgs, arg)
+			}
+
+			p.store.Lock()
+			if reply, e = conn.Do(args[0], cmdArgs...); e != nil {
+				reply = e
+			}
+			p.store.Unlock()
+		}
+
+		writeRESPReply(writer, reply, resp3)
+		if writer.Flush() != nil {
+			return
+		}
+	}
+}
+
+// deny the commands, they are replied NOPERM as the acl user has no
+// permission to run them
+func (p *fakeRedisServer) deny(cmds ...string) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	p.store.denied = map[string]bool{}
+	for _, cmd := range cmds {
+		p.store.denied[strings.ToUpper(cmd)] = true
+	}
+}
+
+func (p *fakeRedisServer) auth(password string) interface{} {
+	if p.password != "" && password != p.password {
+		return redigo.Error("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	return "OK"
+}
+
+func (p *fakeRedisServer) sentinelReply(name string) interface{} {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	address, exist := p.masters[name]
+	if !exist {
+		return nil
+	}
+
+	host, port, _ := net.SplitHostPort(address)
+	return []interface{}{[]byte(host), []byte(port)}
+}
+
+// setSentinelMaster set the master of name, it is the failover of sentinel
+func (p *fakeRedisServer) setSentinelMaster(name, address string) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	if p.masters == nil {
+		p.masters = map[string]string{}
+	}
+	p.masters[name] = address
+}
+
+func (p *fakeRedisServer) setRole(role string) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	p.role = role
+}
+
+// helloReply is the map reply of HELLO
+type helloReply struct {
+	proto string
+}
+
+// readRESPCommand read the command of client, it is an array of bulk strings
+func readRESPCommand(reader *bufio.Reader) (args []string, err error) {
+	line := ""
+	if line, err = readRESPLine(reader); err != nil {
+		return
+	} else if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	count, _ := strconv.Atoi(line[1:])
+	for i := 0; i < count; i++ {
+		if line, err = readRESPLine(reader); err != nil {
+			return
+		}
+
+		size, _ := strconv.Atoi(line[1:])
+		data := make([]byte, size+2)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return
+		}
+		args = append(args, string(data[:size]))
+	}
+
+	return
+}
+
+func readRESPLine(reader *bufio.Reader) (line string, err error) {
+	if line, err = reader.ReadString('\n'); err != nil {
+		return
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writeRESPReply write the reply by RESP2, or RESP3 after HELLO 3, the
+// memory redis only has the null reply different in them
+func writeRESPReply(writer *bufio.Writer, reply interface{}, resp3 bool) {
+	switch v := reply.(type) {
+	case nil:
+		if resp3 {
+			writer.WriteString("_\r\n")
+		} else {
+			writer.WriteString("$-1\r\n")
+		}
+	case helloReply:
+		if resp3 {
+			writer.WriteString("%3\r\n")
+		} else {
+			writer.WriteString("*6\r\n")
+		}
+		fmt.Fprintf(writer, "$6\r\nserver\r\n$5\r\nredis\r\n$7\r\nversion\r\n$5\r\n7.0.0\r\n$5\r\nproto\r\n:%s\r\n", v.proto)
+	case string:
+		fmt.Fprintf(writer, "+%s\r\n", v)
+	case []byte:
+		fmt.Fprintf(writer, "$%d\r\n%s\r\n", len(v), v)
+	case int64:
+		fmt.Fprintf(writer, ":%d\r\n", v)
+	case redigo.Error:
+		fmt.Fprintf(writer, "-%s\r\n", v)
+	case error:
+		fmt.Fprintf(writer, "-ERR %s\r\n", v)
+	case []interface{}:
+		fmt.Fprintf(writer, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeRESPReply(writer, item, resp3)
+		}
+	default:
+		fmt.Fprintf(writer, "-ERR unexpected reply %v\r\n", v)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/gogap/errors"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// Watch should be called before reading the keys, so the transaction
+// will be aborted while they were modified by others
+func (p *redisPipeline) Watch(keys ...string) (err error) {
+	if len(keys) == 0 {
+		return
+	}
+
+	if cluster, ok := p.conn.(*clusterConn); ok {
+		return cluster.Watch(keys...)
+	}
+
+	args := []interface{}{}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	if _, e := p.conn.Do("WATCH", args...); e != nil {
+		err = redisCommandError("WATCH", e, ERR_WATCH_REDIS_KEYS_FAILED.New(errors.Params{"err": e}))
+		return
+	}
+
+	return
+}
+
+// Exec apply the commands in one MULTI/EXEC transaction
+func (p *redisPipeline) Exec(commands []redisCommand) (err error) {
+	if cluster, ok := p.conn.(*clusterConn); ok {
+		return cluster.Exec(commands)
+	}
+
+	return execTransaction(p.conn, commands)
+}
+
+// execTransaction send MULTI, the commands and EXEC at once, the replies of
+// queuing are received one by one, so the command rejected while queued,
+// such as by the acl, is reported instead of the EXECABORT of EXEC
+func execTransaction(conn redigo.Conn, commands []redisCommand) (err error) {
+	if e := conn.Send("MULTI"); e != nil {
+		err = ERR_EXEC_TRANSACTION_FAILED.New(errors.Params{"err": e})
+		return
+	}
+
+	for _, cmd := range commands {
+		if e := conn.Send(cmd.Name, cmd.Args...); e != nil {
+			conn.Do("DISCARD")
+			err = ERR_EXEC_TRANSACTION_FAILED.New(errors.Params{"err": e})
+			return
+		}
+	}
+
+	if e := conn.Send("EXEC"); e != nil {
+		conn.Do("DISCARD")
+		err = ERR_EXEC_TRANSACTION_FAILED.New(errors.Params{"err": e})
+		return
+	}
+
+	if e := conn.Flush(); e != nil {
+		err = redisCommandError("MULTI", e, ERR_EXEC_TRANSACTION_FAILED.New(errors.Params{"err": e}))
+		return
+	}
+
+	var queueErr error
+	for i := -1; i < len(commands); i++ {
+		name := "MULTI"
+		if i >= 0 {
+			name = commands[i].Name
+		}
+
+		if _, e := conn.Receive(); e != nil {
+			if _, ok := e.(redigo.Error); !ok {
+				err = redisCommandError(name, e, ERR_EXEC_TRANSACTION_FAILED.New(errors.Params{"err": e}))
+				return
+			} else if queueErr == nil {
+				queueErr = redisCommandError(name, e, ERR_EXEC_TRANSACTION_FAILED.New(errors.Params{"err": e}))
+			}
+		}
+	}
+
+	replies, e := redigo.Values(conn.Receive())
+	if queueErr != nil {
+		err = queueErr
+		return
+	} else if e == redigo.ErrNil {
+		err = ERR_TRANSACTION_ABORTED_BY_WATCH.New()
+		return
+	} else if e != nil {
+		err = redisCommandError("EXEC", e, ERR_EXEC_TRANSACTION_FAILED.New(errors.Params{"err": e}))
+		return
+	}
+
+	for i, reply := range replies {
+		if e, ok := reply.(redigo.Error); ok {
+			err = ERR_TRANSACTION_PARTIAL_APPLIED.New(errors.Params{"cmd": commands[i].Name, "args": commands[i].Args, "err": e})
+			return
+		}
+	}
+
+	return
+}
+
+// getPushCommands return the write commands of push data, originValue is
+// the current value of set or zset in redis, the members not exist in local
+// will be removed
+func getPushCommands(data PushData, originValue string) (commands []redisCommand, err error) {
+	fnAppend := func(name string, args ...interface{}) {
+		commands = append(commands, redisCommand{Name: name, Args: args})
+	}
+
+	switch data.Type {
+	case "string":
+		{
+			fnAppend("SET", data.Key, data.Value)
+		}
+	case "hash":
+		{
+			fnAppend("HSET", data.Key, data.Field, data.Value)
+		}
+	case "list":
+		{
+			var vals []string
+			if vals, err = unmarshalStringArray(data.Value); err != nil {
+				err = ERR_COULD_NOT_CONV_VAL_TO_ARRAY.New(errors.Params{"val": data.Value, "err": err})
+				return
+			}
+
+			fnAppend("DEL", data.Key)
+			for _, v := range vals {
+				fnAppend("RPUSH", data.Key, v)
+			}
+		}
+	case "set":
+		{
+			var vals, originVals []string
+			if vals, err = unmarshalStringArray(data.Value); err != nil {
+				err = ERR_COULD_NOT_CONV_VAL_TO_ARRAY.New(errors.Params{"val": data.Value, "err": err})
+				return
+			}
+
+			if originValue != "" {
+				if originVals, err = unmarshalStringArray(originValue); err != nil {
+					err = ERR_COULD_NOT_CONV_VAL_TO_ARRAY.New(errors.Params{"val": originValue, "err": err})
+					return
+				}
+			}
+
+			localMembers := map[string]bool{}
+			for _, v := range vals {
+				localMembers[v] = true
+				fnAppend("SADD", data.Key, v)
+			}
+
+			for _, v := range originVals {
+				if !localMembers[v] {
+					fnAppend("SREM", data.Key, v)
+				}
+			}
+		}
+	case "zset":
+		{
+			vals := map[string]zsetScore{}
+			originVals := map[string]zsetScore{}
+			if e := json.Unmarshal([]byte(data.Value), &vals); e != nil {
+				err = ERR_COULD_NOT_CONV_VAL_TO_MAP.New(errors.Params{"val": data.Value, "err": e})
+				return
+			}
+
+			if originValue != "" {
+				if e := json.Unmarshal([]byte(originValue), &originVals); e != nil {
+					err = ERR_COULD_NOT_CONV_VAL_TO_MAP.New(errors.Params{"val": originValue, "err": e})
+					return
+				}
+			}
+
+			for member, score := range vals {
+				fnAppend("ZADD", data.Key, score.String(), member)
+			}
+
+			for member := range originVals {
+				if _, exist := vals[member]; !exist {
+					fnAppend("ZREM", data.Key, member)
+				}
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/gogap/errors"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	_TTL_FILE = ".redis_sync/ttl"
+)
+
+type ttlDrift struct {
+	Key      string
+	LocalTTL int64
+	RedisTTL int64
+}
+
+func readTTLFile() (ttls map[string]int64, err error) {
+	ttls = map[string]int64{}
+
+	if data, e := ioutil.ReadFile(_TTL_FILE); e != nil {
+		if !os.IsNotExist(e) {
+			err = ERR_READ_DATAFILE_ERROR.New(errors.Params{"fileName": _TTL_FILE, "err": e})
+		}
+		return
+	} else if e := json.Unmarshal(data, &ttls); e != nil {
+		err = ERR_PARSE_DATAFILE_ERROR.New(errors.Params{"fileName": _TTL_FILE, "err": e})
+		return
+	}
+
+	return
+}
+
+func writeTTLFile(ttls map[string]int64) (err error) {
+	if data, e := json.MarshalIndent(ttls, "", "    "); e != nil {
+		err = ERR_SERIALIZE_DATAFILE_FAILED.New(errors.Params{"fileName": _TTL_FILE, "err": e})
+		return
+	} else if e := ioutil.WriteFile(_TTL_FILE, data, 0644); e != nil {
+		err = ERR_SAVE_DATAFILE_FAILED.New(errors.Params{"fileName": _TTL_FILE, "err": e})
+		return
+	}
+
+	return
+}
+
+// the redis ttl is counting down, so it only drifted while the key
+// is persistent on one side, or redis ttl is longer than local ttl
+func isTTLDrifted(localTTL int64, localExist bool, redisTTL int64) bool {
+	if redisTTL == -2 {
+		return false
+	}
+
+	if !localExist {
+		return redisTTL >= 0
+	}
+
+	return redisTTL == -1 || redisTTL > localTTL
+}
+
+func getTTLDrifts(keys []string, ttls map[string]int64) (drifts []ttlDrift, err error) {
+	var redisTTLs map[string]int64
+	if redisTTLs, err = getRedisTTLs(keys); err != nil {
+		return
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		redisTTL := redisTTLs[key]
+
+		localTTL, localExist := ttls[key]
+		if !localExist {
+			localTTL = -1
+		}
+
+		if isTTLDrifted(localTTL, localExist, redisTTL) {
+			drifts = append(drifts, ttlDrift{Key: key, LocalTTL: localTTL, RedisTTL: redisTTL})
+		}
+	}
+
+	return
+}
+
+// getTTLCommands return the EXPIRE commands of the keys, and the PERSIST
+// commands of the keys have no local ttl, they are read before the push
+// commands are applied, so the ttl cleared by SET and DEL, and the keys
+// created by push are taken as persistent
+func getTTLCommands(keys []string, pushCommands []redisCommand) (commands []redisCommand, err error) {
+	var ttls map[string]int64
+	if ttls, err = readTTLFile(); err != nil {
+		return
+	}
+
+	var redisTTLs map[string]int64
+	if redisTTLs, err = getRedisTTLs(keys); err != nil {
+		return
+	}
+
+	written := map[string]bool{}
+	cleared := map[string]bool{}
+	for _, cmd := range pushCommands {
+		key := fmt.Sprint(cmd.Args[0])
+		written[key] = true
+		if cmd.Name == "SET" || cmd.Name == "DEL" {
+			cleared[key] = true
+		}
+	}
+
+	for _, key := range keys {
+		localTTL, localExist := ttls[key]
+
+		redisTTL := redisTTLs[key]
+		if cleared[key] || (written[key] && redisTTL == -2) {
+			redisTTL = -1
+		}
+
+		if !isTTLDrifted(localTTL, localExist, redisTTL) {
+			continue
+		}
+
+		if localExist {
+			commands = append(commands, redisCommand{Name: "EXPIRE", Args: []interface{}{key, localTTL}})
+		} else {
+			commands = append(commands, redisCommand{Name: "PERSIST", Args: []interface{}{key}})
+		}
+	}
+
+	return
+}
+
+func expireOperation(cmd redisCommand) outputOperation {
+	key := cmd.Args[0].(string)
+	if cmd.Name == "PERSIST" {
+		return outputOperation{Op: "PERSIST", Key: key, line: fmt.Sprintf("[PERSIST]\t '%s'", key)}
+	}
+
+	ttl := cmd.Args[1].(int64)
+	return outputOperation{Op: "EXPIRE", Key: key, TTL: &ttl, line: fmt.Sprintf("[EXPIRE]\t '%s' '%d'", key, ttl)}
+}
+
+func pullTTLs(keys []string) (err error) {
+	var ttls map[string]int64
+	if ttls, err = readTTLFile(); err != nil {
+		return
+	}
+
+	var redisTTLs map[string]int64
+	if redisTTLs, err = getRedisTTLs(keys); err != nil {
+		return
+	}
+
+	newTTLs := map[string]int64{}
+
+	// the keys out of scope are not pulled, keep them as they are
+	for key, ttl := range ttls {
+		if !conf.InScope(key) {
+			newTTLs[key] = ttl
+		}
+	}
+
+	for _, key := range keys {
+		redisTTL := redisTTLs[key]
+
+		if redisTTL < 0 {
+			continue
+		}
+
+		// keep the local ttl, the redis ttl is only the remaining time
+		if localTTL, exist := ttls[key]; exist && localTTL >= redisTTL {
+			newTTLs[key] = localTTL
+		} else {
+			newTTLs[key] = redisTTL
+		}
+	}
+
+	if len(newTTLs) == 0 && len(ttls) == 0 {
+		return
+	}
+
+	return writeTTLFile(newTTLs)
+}
+
+// getRedisTTLs read the ttl of keys in batches, the ttl is read by PTTL and
+// rounded up to seconds, so the key expiring in less than one second is not
+// taken as 0, and the ttl just set by EXPIRE is never longer than local
+func getRedisTTLs(keys []string) (ttls map[string]int64, err error) {
+	ttls = map[string]int64{}
+
+	if len(keys) == 0 {
+		return
+	}
+
+	var conn redigo.Conn
+	if conn, err = dialRedis(); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	commands := []redisCommand{}
+	for _, key := range keys {
+		commands = append(commands, redisCommand{Name: "PTTL", Args: []interface{}{key}})
+	}
+
+	var replies []interface{}
+	if replies, err = newRedisPipeline(conn, 0).Run(commands); err != nil {
+		return
+	}
+
+	for i, reply := range replies {
+		var ttl int64
+		if ttl, err = redigo.Int64(reply, nil); err != nil {
+			err = ERR_GET_REDIS_KEY_TTL_FAILED.New(errors.Params{"key": keys[i], "err": err})
+			return
+		}
+
+		if ttl > 0 {
+			ttl = (ttl + 999) / 1000
+		}
+		ttls[keys[i]] = ttl
+	}
+
+	return
+}
+
+func printTTLDrifts() (err error) {
+	var localData map[string][]PushData
+	if localData, err = getLocalData(); err != nil {
+		return
+	}
+
+	var ttls map[string]int64
+	if ttls, err = readTTLFile(); err != nil {
+		return
+	}
+
+	return printDataTTLDrifts(localData, ttls)
+}
+
+// printDataTTLDrifts print the ttl drifts of the keys of localData, the
+// local ttl are given, so they could be read from a commit
+func printDataTTLDrifts(localData map[string][]PushData, ttls map[string]int64) (err error) {
+	keys := []string{}
+	for key := range localData {
+		keys = append(keys, key)
+	}
+
+	var drifts []ttlDrift
+	if drifts, err = getTTLDrifts(keys, ttls); err != nil {
+		return
+	}
+
+	for _, drift := range drifts {
+		localTTL, redisTTL := drift.LocalTTL, drift.RedisTTL
+		printOperation(true, outputOperation{Op: "TTL", Key: drift.Key, LocalTTL: &localTTL, RedisTTL: &redisTTL,
+			line: fmt.Sprintf("[TTL]\t '%s' local: %d, redis: %d", drift.Key, drift.LocalTTL, drift.RedisTTL)})
+	}
+
+	return
+}
@@ -9,7 +9,18 @@ import (
 	"github.com/gogap/errors"
 )
 
+var (
+	// exit is os.Exit, the tests replace it to get the exit code
+	exit = os.Exit
+)
+
 func exitError(err error) {
+	if outputJSON {
+		printErrorOutput(err)
+		exit(exitCode(err))
+		return
+	}
+
 	if errCode, ok := err.(errors.ErrCode); ok {
 		if viewDetails {
 			fmt.Printf("[ERR-%s-%d] %s \n%s\n", errCode.Namespace(), errCode.Code(), errCode.Error(), errCode.StackTrace())
@@ -20,10 +31,15 @@ func exitError(err error) {
 		fmt.Printf("[ERR-%s] %s \n", REDIS_SYNC_ERR_NS, err.Error())
 	}
 
-	os.Exit(1)
+	exit(exitCode(err))
 }
 
 func serializeObject(obj interface{}) (str string, err error) {
+	if obj == nil {
+		err = errors.New("the value is null")
+		return
+	}
+
 	switch reflect.TypeOf(obj).Kind() {
 	case reflect.Map, reflect.Array, reflect.Slice:
 		{
@@ -49,3 +65,14 @@ func unmarshalJsonArray(data string) ([]interface{}, error) {
 	err := json.Unmarshal([]byte(data), &ret)
 	return ret, err
 }
+
+func serializeStrings(vals []string) (string, error) {
+	data, err := json.Marshal(vals)
+	return string(data), err
+}
+
+func unmarshalStringArray(data string) ([]string, error) {
+	var ret []string
+	err := json.Unmarshal([]byte(data), &ret)
+	return ret, err
+}